@@ -0,0 +1,142 @@
+package agmi
+
+import "strings"
+
+// Document is the abstract syntax tree of an Almost Gemtext document.
+type Document struct {
+	Blocks []Block // Blocks of the document in the order of their appearance.
+}
+
+// Block is a top-level element of an Almost Gemtext document.
+//
+// The concrete types implementing Block are *Modeline, *Heading,
+// *Paragraph, *List, *Quote, *PreFormatted, and *Link. Further types may be
+// added in the future. Code using a type switch on Block should therefore
+// always have a default case.
+type Block interface {
+	// Pos returns the position of the first byte of the block.
+	Pos() Pos
+
+	block()
+}
+
+// Modeline is a modeline at the beginning or the end of a document.
+type Modeline struct {
+	Start Pos
+	Text  string // Text of the modeline including the leading <!--.
+}
+
+// Heading is a heading of the document.
+type Heading struct {
+	Start Pos
+	Level int    // Number of leading pound characters.
+	Text  string // Text of the heading without the leading pound characters.
+}
+
+// Paragraph is a paragraph of text.
+type Paragraph struct {
+	Start      Pos
+	Lines      []string // Lines of the paragraph as found in the document.
+	HardBreaks []int    // Indices of all Lines ending in a hard break.
+}
+
+// Text returns the text of the paragraph with all lines joined by a single
+// space. Lines ending in a hard break are joined by a newline instead.
+func (p *Paragraph) Text() string {
+	return joinLines(p.Lines, p.HardBreaks)
+}
+
+// List is a list of one or more list items.
+type List struct {
+	Start Pos
+	Items []*ListItem
+}
+
+// ListItem is an item of a List.
+type ListItem struct {
+	Start      Pos
+	Lines      []string // Lines of the item without bullet point and indentation.
+	HardBreaks []int    // Indices of all Lines ending in a hard break.
+}
+
+// Text returns the text of the list item with all lines joined by a single
+// space. Lines ending in a hard break are joined by a newline instead.
+func (li *ListItem) Text() string {
+	return joinLines(li.Lines, li.HardBreaks)
+}
+
+// Quote is a quote spanning one or more lines.
+type Quote struct {
+	Start      Pos
+	Lines      []string // Lines of the quote without the leading >.
+	HardBreaks []int    // Indices of all Lines ending in a hard break.
+}
+
+// Text returns the text of the quote with all lines joined by a single
+// space. Lines ending in a hard break are joined by a newline instead.
+func (q *Quote) Text() string {
+	return joinLines(q.Lines, q.HardBreaks)
+}
+
+// PreFormatted is a block of pre-formatted text.
+type PreFormatted struct {
+	Start    Pos
+	AltText  string   // Text following the opening ```, if any.
+	Lines    []string // Lines of pre-formatted text without the identifying indentation.
+	Indented bool     // The text was identified by indentation instead of ```.
+}
+
+// Link is a link to another document.
+type Link struct {
+	Start Pos
+	URI   string
+	Text  string // Text of the link without leading white space. May be empty.
+}
+
+// Pos returns the position of the first byte of the modeline.
+func (m *Modeline) Pos() Pos { return m.Start }
+
+// Pos returns the position of the first byte of the heading.
+func (h *Heading) Pos() Pos { return h.Start }
+
+// Pos returns the position of the first byte of the paragraph.
+func (p *Paragraph) Pos() Pos { return p.Start }
+
+// Pos returns the position of the first byte of the list.
+func (l *List) Pos() Pos { return l.Start }
+
+// Pos returns the position of the first byte of the quote.
+func (q *Quote) Pos() Pos { return q.Start }
+
+// Pos returns the position of the first byte of the pre-formatted text.
+func (pf *PreFormatted) Pos() Pos { return pf.Start }
+
+// Pos returns the position of the first byte of the link.
+func (l *Link) Pos() Pos { return l.Start }
+
+func (*Modeline) block()     {}
+func (*Heading) block()      {}
+func (*Paragraph) block()    {}
+func (*List) block()         {}
+func (*Quote) block()        {}
+func (*PreFormatted) block() {}
+func (*Link) block()         {}
+
+// joinLines joins lines by a single space, or by a newline if the index of
+// the line is contained in hardBreaks.
+func joinLines(lines []string, hardBreaks []int) string {
+	var sb strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			sep := " "
+			for _, j := range hardBreaks {
+				if j == i-1 {
+					sep = "\n"
+				}
+			}
+			sb.WriteString(sep)
+		}
+		sb.WriteString(l)
+	}
+	return sb.String()
+}
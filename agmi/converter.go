@@ -0,0 +1,122 @@
+package agmi
+
+import (
+	"fmt"
+	"io"
+)
+
+// ConverterState is a function that processes the current token of the input.
+//
+// Any ConverterState may use the next to make decisions on how to process the
+// current Token or to update the Converter state. Tokens further ahead are
+// available through the Peek method of the Converter. However, it is
+// absolutely necessary that the current token is processed one way or the
+// other. After cur is passed to a ConverterState function it will not be
+// passed again during the remainder of the formatting run.
+type ConverterState func(c *Converter, cur, next Token)
+
+// Converter is a type that helps implementing the conversion from
+// Almost Gemtext to some other format.
+//
+// The zero value of Converter is not usable. Use the NewConverter
+// function to obtain a working instance.
+type Converter struct {
+	State ConverterState // Current state of the Converter. Update when transitioning.
+	Err   error          // Set this field if an error occurs while processing a token.
+
+	tokens TokenStream
+	buf    []Token // Tokens read from tokens but not yet processed. The first one is the current token.
+	eof    bool    // tokens is exhausted.
+	out    io.Writer
+}
+
+// NewConverter creates a new Converter that writes its input in
+// converted form to out.
+//
+// The start ConverterState is the state the converter helper starts with when
+// processing begins. The filters are applied in order to the Tokens of the
+// input before they are passed to any ConverterState.
+func NewConverter(in io.Reader, out io.Writer, start ConverterState, filters ...Filter) Converter {
+	return Converter{
+		State:  start,
+		tokens: ApplyFilters(NewScanner(in), filters...),
+		out:    out,
+	}
+}
+
+// Convert processes the input and writes it in a converted form to the
+// output.
+//
+// Empty documents and documents consisting of blank lines only are
+// converted to empty output. In this case no ConverterState is called.
+func (c *Converter) Convert() error {
+	const op = "agmi/Converter.Format"
+
+	if c.State == nil {
+		return fmt.Errorf("%s: initial state not set", op)
+	}
+
+	// Look for the first Token which is not blank.
+	i := 0
+	for c.Peek(i).IsBlank() {
+		i++
+	}
+	if len(c.buf) <= i {
+		if c.tokens.Err() != nil {
+			return fmt.Errorf("%s: %w", op, c.tokens.Err())
+		}
+		return nil
+	}
+
+	for len(c.buf) > 0 {
+		cur, next := c.buf[0], c.Peek(1)
+		if c.tokens.Err() != nil {
+			break
+		}
+
+		c.State(c, cur, next)
+		if c.Err != nil {
+			return fmt.Errorf("%s: %w", op, c.Err)
+		}
+		c.buf = c.buf[1:]
+	}
+	if c.tokens.Err() != nil {
+		return fmt.Errorf("%s: %w", op, c.tokens.Err())
+	}
+	return nil
+}
+
+// Peek returns the Token i positions after the current Token without
+// consuming it. Peek(1) returns the same Token as passed as next to the
+// current ConverterState.
+//
+// Peek returns the zero Token if the input ends before the requested
+// Token.
+func (c *Converter) Peek(i int) Token {
+	if i < 0 || !c.fill(i+1) {
+		return Token{}
+	}
+	return c.buf[i]
+}
+
+// fill reads Tokens until the buffer contains at least n of them. It
+// returns false if the input does not contain enough Tokens.
+func (c *Converter) fill(n int) bool {
+	for len(c.buf) < n {
+		if c.eof || !c.tokens.Scan() {
+			c.eof = true
+			return false
+		}
+		c.buf = append(c.buf, c.tokens.Token())
+	}
+	return true
+}
+
+// Write writes the string s to the output.
+func (c *Converter) Write(s string) {
+	const op = "agmi/Converter.Write"
+
+	if _, err := c.out.Write([]byte(s)); err != nil {
+		c.Err = fmt.Errorf("%s: %w", op, err)
+	}
+}
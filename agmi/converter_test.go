@@ -0,0 +1,74 @@
+package agmi_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConverter_Peek(t *testing.T) {
+	var (
+		out    strings.Builder
+		peeked []string
+	)
+
+	c := agmi.NewConverter(strings.NewReader("* a\n* b\n"), &out, func(c *agmi.Converter, cur, next agmi.Token) {
+		assert.Equal(t, next, c.Peek(1))
+		peeked = append(peeked, c.Peek(2).Text)
+		c.Write(cur.Text)
+	})
+	err := c.Convert()
+	assert.NoError(t, err)
+	assert.Equal(t, "* a\n* b\n", out.String())
+	assert.Equal(t, []string{"\n", "* ", "b", "\n", "", ""}, peeked)
+}
+
+func TestConverter_Filters(t *testing.T) {
+	var out strings.Builder
+
+	upper := func(ts agmi.TokenStream) agmi.TokenStream {
+		return agmi.FilterTokens(ts, func(tok agmi.Token, emit func(agmi.Token)) error {
+			tok.Text = strings.ToUpper(tok.Text)
+			if !tok.IsZero() {
+				emit(tok)
+			}
+			return nil
+		})
+	}
+	copyToken := func(c *agmi.Converter, cur, _ agmi.Token) {
+		c.Write(cur.Text)
+	}
+	c := agmi.NewConverter(strings.NewReader("<!-- modeline -->\nsome text"), &out, copyToken,
+		agmi.StripModelines(), upper)
+	err := c.Convert()
+	assert.NoError(t, err)
+	assert.Equal(t, "SOME TEXT", out.String())
+}
+
+func TestConverter_StateError(t *testing.T) {
+	var calls int
+
+	c := agmi.NewConverter(strings.NewReader("a\nb"), &strings.Builder{}, func(c *agmi.Converter, _, _ agmi.Token) {
+		calls++
+		c.Err = errors.New("failed")
+	})
+	err := c.Convert()
+	assert.EqualError(t, err, "agmi/Converter.Format: failed")
+	assert.Equal(t, 1, calls)
+}
+
+func TestConverter_EmptyDocument(t *testing.T) {
+	for _, input := range []string{"", "\n\n", "  \n\t\n"} {
+		var out strings.Builder
+
+		c := agmi.NewConverter(strings.NewReader(input), &out, func(c *agmi.Converter, _, _ agmi.Token) {
+			t.Errorf("state called for input %q", input)
+		})
+		err := c.Convert()
+		assert.NoError(t, err)
+		assert.Empty(t, out.String())
+	}
+}
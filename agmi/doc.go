@@ -0,0 +1,39 @@
+// Package agmi reads Almost Gemtext documents.
+//
+// Almost Gemtext is the input format of mnml. It is specified in
+// docs/almost_gemtext.agmi within the mnml repository.
+//
+// The package offers three levels of abstraction:
+//
+// The Scanner splits a document into a stream of Tokens. It is the basis of
+// everything else in this package and useful for tools which need to know
+// the exact position of every character, e.g. linters or syntax
+// highlighters.
+//
+// The Converter helps to implement conversions from Almost Gemtext to other
+// formats as state machines processing the Token stream. The converters
+// shipped with mnml, e.g. the one in package gemtext, are built this way.
+//
+// Parse reads a whole document into an abstract syntax tree of Blocks. This
+// is the most convenient way to write renderers which do not need to stream
+// their input.
+//
+// Compatibility
+//
+// The exported API of this package is stable. Future versions of mnml will
+// not remove or change exported identifiers in a way that breaks code using
+// them, with the following exceptions:
+//
+// New TokenTypes may be added as the Almost Gemtext specification evolves.
+// The numeric values of TokenTypes are not part of the API and may change.
+// Always use the named constants.
+//
+// New types implementing Block may be added. Type switches on Block should
+// always have a default case.
+//
+// New fields may be added to structs. Use keyed fields in composite
+// literals.
+//
+// Bugs in the Scanner may be fixed even if this changes the Tokens emitted
+// for invalid or unusual input.
+package agmi
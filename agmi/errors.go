@@ -0,0 +1,81 @@
+package agmi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyInput was returned by a Converter if its input did not contain a
+// single Token.
+//
+// Deprecated: Converters convert empty input to empty output and never
+// return ErrEmptyInput.
+var ErrEmptyInput = errors.New("empty input")
+
+// SyntaxErrorKind classifies a SyntaxError.
+type SyntaxErrorKind string
+
+const (
+	// SyntaxErrorTokenTooLong is the kind of a SyntaxError returned by the
+	// Scanner if a Token exceeds the maximum token size and cannot be split.
+	SyntaxErrorTokenTooLong SyntaxErrorKind = "token-too-long"
+
+	// SyntaxErrorInclude is the kind of a SyntaxError returned by
+	// ExpandIncludes if an included document cannot be read.
+	SyntaxErrorInclude SyntaxErrorKind = "include"
+
+	// SyntaxErrorIncludeCycle is the kind of a SyntaxError returned by
+	// ExpandIncludes if a document includes itself, directly or indirectly.
+	SyntaxErrorIncludeCycle SyntaxErrorKind = "include-cycle"
+)
+
+// Kinds of the SyntaxErrors returned by converters in strict mode. They
+// equal the names of the rules reported by mnml check.
+const (
+	// SyntaxErrorListParagraph is the kind of a SyntaxError for a list
+	// which does not have a paragraph of its own.
+	SyntaxErrorListParagraph SyntaxErrorKind = "list-paragraph"
+
+	// SyntaxErrorUnclosedPreFmt is the kind of a SyntaxError for
+	// pre-formatted text started with ``` which is never closed.
+	SyntaxErrorUnclosedPreFmt SyntaxErrorKind = "unclosed-prefmt"
+
+	// SyntaxErrorLinkWithoutURI is the kind of a SyntaxError for a link
+	// without an URI.
+	SyntaxErrorLinkWithoutURI SyntaxErrorKind = "link-without-uri"
+
+	// SyntaxErrorHeadingLevel is the kind of a SyntaxError for a heading
+	// with more than six levels.
+	SyntaxErrorHeadingLevel SyntaxErrorKind = "heading-level"
+)
+
+// SyntaxError describes a problem at a specific position of an Almost Gemtext
+// document.
+//
+// Use errors.As to find out if an error was caused by a SyntaxError:
+//
+//	var serr *agmi.SyntaxError
+//	if errors.As(err, &serr) {
+//	    fmt.Printf("%s: %s\n", serr.Pos, serr.Msg)
+//	}
+type SyntaxError struct {
+	File string          // Document containing the problem. Empty for the document being processed.
+	Pos  Pos             // Position of the problem within the document.
+	Kind SyntaxErrorKind // Kind of the problem.
+	Msg  string          // Human readable description of the problem.
+	Err  error           // Underlying error, if any.
+}
+
+// Error returns the position and the description of the problem formatted as
+// line:col: msg, or as file:line:col: msg if File is set.
+func (e *SyntaxError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%s: %s", e.File, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,87 @@
+package agmi_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fhofherr/mnml/agmi"
+)
+
+func ExampleScanner() {
+	input := "# Title\n\n=> gemini://example.com Example\n"
+
+	sc := agmi.NewScanner(strings.NewReader(input))
+	for sc.Scan() {
+		tok := sc.Token()
+		fmt.Printf("%s %s %q\n", tok.Pos, tok.Type, tok.Text)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1:1 HeadingMod "# "
+	// 1:3 Text "Title"
+	// 1:8 ParSep "\n\n"
+	// 3:1 LinkMod "=> "
+	// 3:4 LinkURI "gemini://example.com"
+	// 3:24 Text " Example"
+	// 3:32 LineBreak "\n"
+}
+
+func ExampleParse() {
+	input := `# Shopping list
+
+* Milk
+* Bread and
+  butter
+
+=> gemini://example.com/recipes.gmi Recipes
+`
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case *agmi.Heading:
+			fmt.Printf("%s: heading level %d: %s\n", b.Pos(), b.Level, b.Text)
+		case *agmi.List:
+			for _, item := range b.Items {
+				fmt.Printf("%s: list item: %s\n", item.Start, item.Text())
+			}
+		case *agmi.Link:
+			fmt.Printf("%s: link to %s: %s\n", b.Pos(), b.URI, b.Text)
+		default:
+			fmt.Printf("%s: %T\n", b.Pos(), b)
+		}
+	}
+	// Output:
+	// 1:1: heading level 1: Shopping list
+	// 3:1: list item: Milk
+	// 4:1: list item: Bread and butter
+	// 7:1: link to gemini://example.com/recipes.gmi: Recipes
+}
+
+func ExampleConverter() {
+	// upperCase converts all text to upper case and copies everything else
+	// verbatim.
+	upperCase := func(c *agmi.Converter, cur, next agmi.Token) {
+		if cur.Type == agmi.TokenTypeText {
+			c.Write(strings.ToUpper(cur.Text))
+			return
+		}
+		c.Write(cur.Text)
+	}
+
+	c := agmi.NewConverter(strings.NewReader("# Title\n\nSome text.\n"), os.Stdout, upperCase)
+	if err := c.Convert(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// # TITLE
+	//
+	// SOME TEXT.
+}
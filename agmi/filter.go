@@ -0,0 +1,188 @@
+package agmi
+
+import "strings"
+
+// StripModelines removes all modelines from a TokenStream. It removes all
+// line breaks and paragraph separators immediately following a modeline as
+// well.
+//
+// Modeline Tokens which do not start a line or which are part of
+// pre-formatted text are not considered modelines and thus retained.
+func StripModelines() Filter {
+	return func(ts TokenStream) TokenStream {
+		var (
+			lt       = newLineTracker()
+			skipping bool
+		)
+
+		return FilterTokens(ts, func(tok Token, emit func(Token)) error {
+			defer lt.update(tok)
+
+			switch {
+			case tok.Type == TokenTypeModeline && lt.isBlockStart():
+				skipping = true
+			case skipping && isLineEnd(tok):
+			case !tok.IsZero():
+				skipping = false
+				emit(tok)
+			}
+			return nil
+		})
+	}
+}
+
+// StripComments removes all comments from a TokenStream.
+//
+// A comment on a line of its own is removed together with the line break
+// ending the line. If blank lines follow the comment, a single blank line is
+// retained. Text following a comment on the same line is kept without any
+// leading white space.
+//
+// White space surrounding a comment within a line is collapsed into a
+// single space. Text ending right before the comment is thus separated from
+// text starting right after it only if the comment was separated from
+// either.
+func StripComments() Filter {
+	return func(ts TokenStream) TokenStream {
+		var (
+			lt       = newLineTracker()
+			lineEnd  Token // Line end held back until the next line is known.
+			text     Token // Text held back until it is known whether a comment follows.
+			skipping bool
+			inline   bool // The previous Token was a comment within a line.
+			space    bool // White space preceded the comment within a line.
+			afterURI bool // The last Token other than text was the URI of a link.
+		)
+
+		flush := func(emit func(Token)) {
+			if !lineEnd.IsZero() {
+				emit(lineEnd)
+				lineEnd = Token{}
+			}
+			if text.Text != "" {
+				emit(text)
+			}
+			text = Token{}
+		}
+
+		return FilterTokens(ts, func(tok Token, emit func(Token)) error {
+			defer lt.update(tok)
+
+			switch {
+			case tok.Type == TokenTypeComment && (skipping || lt.isBlockStart()):
+				skipping = true
+			case tok.Type == TokenTypeComment:
+				trimmed := strings.TrimRight(text.Text, " \t")
+				space = space || trimmed != text.Text
+				text.Text = trimmed
+				inline = true
+			case skipping && isLineEnd(tok):
+				if tok.Type == TokenTypeParSep && lineEnd.Type == TokenTypeLineBreak {
+					lineEnd = Token{Type: TokenTypeParSep, Pos: lineEnd.Pos, Text: "\n\n"}
+				}
+			case isLineEnd(tok):
+				flush(emit)
+				lineEnd = tok
+				inline, space, afterURI = false, false, false
+			case (skipping || inline) && !lt.lineStart && tok.Type == TokenTypeText:
+				// Text following a comment on the same line.
+				trimmed := strings.TrimLeft(tok.Text, " \t")
+				if trimmed == "" {
+					space = true
+					return nil
+				}
+				// Keep the text separated from the preceding text or from
+				// the URI of a link.
+				if inline && (text.Text != "" || afterURI) && (space || trimmed != tok.Text) {
+					trimmed = " " + trimmed
+				}
+				tok.Text = trimmed
+				fallthrough
+			default:
+				skipping, inline, space = false, false, false
+				flush(emit)
+				if tok.Type == TokenTypeText {
+					text = tok
+				} else if !tok.IsZero() {
+					afterURI = tok.Type == TokenTypeLinkURI
+					emit(tok)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// RewriteLinks passes the URI of every link to rewrite and replaces it with
+// the returned URI.
+//
+// A long URI may be split into several Tokens by the Scanner. RewriteLinks
+// joins them into a single Token before passing the URI to rewrite. Links
+// within pre-formatted text are not changed.
+func RewriteLinks(rewrite func(uri string) string) Filter {
+	return func(ts TokenStream) TokenStream {
+		var (
+			lt     = newLineTracker()
+			inLink bool
+			uri    strings.Builder
+			first  Token // First Token of the URI.
+		)
+
+		return FilterTokens(ts, func(tok Token, emit func(Token)) error {
+			defer lt.update(tok)
+
+			if tok.Type == TokenTypeLinkMod {
+				inLink = lt.isBlockStart()
+			}
+			if inLink && tok.Type == TokenTypeLinkURI {
+				if uri.Len() == 0 {
+					first = tok
+				}
+				uri.WriteString(tok.Text)
+				return nil
+			}
+			if uri.Len() > 0 {
+				first.Text = rewrite(uri.String())
+				emit(first)
+				uri.Reset()
+			}
+			if isLineEnd(tok) {
+				inLink = false
+			}
+			if !tok.IsZero() {
+				emit(tok)
+			}
+			return nil
+		})
+	}
+}
+
+// lineTracker keeps track of the beginnings of lines and of pre-formatted
+// text within a TokenStream.
+type lineTracker struct {
+	lineStart bool // The next Token starts a line.
+	preFmt    bool // The next Token is part of text enclosed in ```.
+}
+
+func newLineTracker() *lineTracker {
+	return &lineTracker{lineStart: true}
+}
+
+// isBlockStart returns true if the next Token may start a block of the
+// document, i.e. it starts a line outside of pre-formatted text.
+func (lt *lineTracker) isBlockStart() bool {
+	return lt.lineStart && !lt.preFmt
+}
+
+// update must be called with every Token of the stream after it was
+// processed.
+func (lt *lineTracker) update(tok Token) {
+	if tok.Type == TokenTypePreFmtMod && lt.lineStart {
+		lt.preFmt = !lt.preFmt
+	}
+	lt.lineStart = isLineEnd(tok)
+}
+
+func isLineEnd(tok Token) bool {
+	return tok.Type == TokenTypeLineBreak || tok.Type == TokenTypeParSep || tok.Type == TokenTypeHardBreak
+}
@@ -0,0 +1,259 @@
+package agmi_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		filters  []agmi.Filter
+		expected string
+	}{
+		{
+			name:     "no filters",
+			input:    "<!-- vim: set tw=72: -->\n\n# Title\n",
+			expected: "<!-- vim: set tw=72: -->\n\n# Title\n",
+		},
+		{
+			name:     "strip modelines",
+			input:    "<!-- vim: set tw=72: -->\n\n# Title\n\n<!-- vim: set ft=markdown: -->\n",
+			filters:  []agmi.Filter{agmi.StripModelines()},
+			expected: "# Title\n\n",
+		},
+		{
+			name:     "keep modelines in pre-formatted text",
+			input:    "```\n<!-- vim: set tw=72: -->\n```\n\n    <!-- not a modeline -->\n",
+			filters:  []agmi.Filter{agmi.StripModelines()},
+			expected: "```\n<!-- vim: set tw=72: -->\n```\n\n    <!-- not a modeline -->\n",
+		},
+		{
+			name:     "strip comments",
+			input:    "First\n<!-- a -->\nline\n\n<!-- b\n\n-->\n\nSecond\n<!-- c -->\n\nThird<!-- d -->\n",
+			filters:  []agmi.Filter{agmi.StripComments()},
+			expected: "First\nline\n\nSecond\n\nThird\n",
+		},
+		{
+			name: "strip comments within lines",
+			input: "Text <!-- a --> more\nText<!-- b -->more <!-- c -->\n* <!-- d --> item\n" +
+				"=> gemini://example.com Link <!-- e --> <!-- f -->text\n\\<!-- g --> and \\<!-- h -->\n",
+			filters: []agmi.Filter{agmi.StripComments()},
+			expected: "Text more\nTextmore\n* item\n" +
+				"=> gemini://example.com Link text\n\\<!-- g --> and \\<!-- h -->\n",
+		},
+		{
+			name:     "keep comments in lines indented like pre-formatted text",
+			input:    "Text\n\n    code <!-- a -->\n\tcode <!-- b -->\n",
+			filters:  []agmi.Filter{agmi.StripComments()},
+			expected: "Text\n\n    code <!-- a -->\n\tcode <!-- b -->\n",
+		},
+		{
+			name:     "strip comments followed by text",
+			input:    "First\n<!-- a --> line\n<!-- b -->  \nlast\n",
+			filters:  []agmi.Filter{agmi.StripComments()},
+			expected: "First\nline\nlast\n",
+		},
+		{
+			name:     "strip comments at the beginning of the document",
+			input:    "<!-- vim: set tw=72: -->\n<!-- a -->\n\n# Title\n",
+			filters:  []agmi.Filter{agmi.StripModelines(), agmi.StripComments()},
+			expected: "# Title\n",
+		},
+		{
+			name:     "table of contents",
+			input:    "# Title\n\n<!-- toc -->\n\n## First\n\n### \\# Sub\n\n```\n## Code\n```\n\n## Second\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "# Title\n\n* First\n* \u00a0\u00a0\\# Sub\n* Second\n\n## First\n\n### \\# Sub\n\n```\n## Code\n```\n\n## Second\n",
+		},
+		{
+			name:     "table of contents separated by paragraphs",
+			input:    "# T\n\n<!-- toc -->\n## A\n\nPara\n<!-- toc -->\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "# T\n\n* A\n\n## A\n\nPara\n<!-- toc -->\n",
+		},
+		{
+			name:     "table of contents within a paragraph",
+			input:    "Para\n<!-- toc --> after\n\n## A",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "Para\n\n* A\n\nafter\n\n## A",
+		},
+		{
+			name:     "table of contents without lines continuing a paragraph",
+			input:    "Intro\n<!-- toc -->\n## B\n### C\n\nText\n## D\n\n## E\\\nmore\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "Intro\n\n* B ### C\n* E more\n\n## B\n### C\n\nText\n## D\n\n## E\\\nmore\n",
+		},
+		{
+			name:     "table of contents on the first line",
+			input:    "<!-- toc -->\n\n<!-- a -->\n# A\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "* A\n\n<!-- a -->\n# A\n",
+		},
+		{
+			name:     "table of contents at the end of a paragraph",
+			input:    "Para\n<!-- toc -->\n\n## A",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "Para\n\n* A\n\n## A",
+		},
+		{
+			name:     "table of contents without comments",
+			input:    "Text\n\n<!-- toc -->\n\n# First <!-- draft --> title\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "Text\n\n* First title\n\n# First <!-- draft --> title\n",
+		},
+		{
+			name:     "table of contents without headings",
+			input:    "# Title\n\n<!-- toc -->\n",
+			filters:  []agmi.Filter{agmi.TableOfContents()},
+			expected: "# Title\n\n<!-- toc -->\n",
+		},
+		{
+			name: "reference links",
+			input: "See [^b] and\n[^a].\n\nAgain [^b], [^c].\n\n```\n[^a]\n```\n\n" +
+				"=> gemini://a.example [^a] A\n=> gemini://b.example [^b]\n=> gemini://u.example [^unused] Unused\n",
+			filters: []agmi.Filter{agmi.ReferenceLinks()},
+			expected: "See [1] and\n[2].\n\n=> gemini://b.example [1]\n=> gemini://a.example [2] A\n\n" +
+				"Again [1], [^c].\n\n```\n[^a]\n```\n\n=> gemini://u.example [^unused] Unused\n",
+		},
+		{
+			name:     "reference links defined within a paragraph",
+			input:    "Text [^a]\n=> gemini://a.example [^a] A\nmore text.\n\n# Next",
+			filters:  []agmi.Filter{agmi.ReferenceLinks()},
+			expected: "Text [1]\nmore text.\n\n=> gemini://a.example [1] A\n\n# Next",
+		},
+		{
+			name:     "reference links at the end of the document",
+			input:    "Text [^a]\n\n=> gemini://a.example [^a] A\n",
+			filters:  []agmi.Filter{agmi.ReferenceLinks()},
+			expected: "Text [1]\n\n=> gemini://a.example [1] A\n",
+		},
+		{
+			name:     "reference links defined before their use",
+			input:    "Text\n=> gemini://a.example [^a] A\n\nMore [^a].\n",
+			filters:  []agmi.Filter{agmi.ReferenceLinks()},
+			expected: "Text\n\nMore [1].\n\n=> gemini://a.example [1] A\n",
+		},
+		{
+			name:  "reference links after consecutive line ends",
+			input: "Roses [^a]\n\nx\nviolets\n\n=> gemini://a.example [^a] A\n",
+			filters: []agmi.Filter{
+				func(ts agmi.TokenStream) agmi.TokenStream {
+					return agmi.FilterTokens(ts, func(tok agmi.Token, emit func(agmi.Token)) error {
+						if tok.Text != "x" && !tok.IsZero() {
+							emit(tok)
+						}
+						return nil
+					})
+				},
+				agmi.ReferenceLinks(),
+			},
+			expected: "Roses [1]\n\n=> gemini://a.example [1] A\n\n\nviolets\n",
+		},
+		{
+			name:  "rewrite links",
+			input: "=> first.agmi First\n=> second.agmi\n\n```\n=> third.agmi\n```\n",
+			filters: []agmi.Filter{agmi.RewriteLinks(func(uri string) string {
+				return strings.TrimSuffix(uri, ".agmi") + ".gmi"
+			})},
+			expected: "=> first.gmi First\n=> second.gmi\n\n```\n=> third.agmi\n```\n",
+		},
+		{
+			name:  "combine filters",
+			input: "<!-- vim: set tw=72: -->\n=> first.agmi First\n",
+			filters: []agmi.Filter{
+				agmi.StripModelines(),
+				agmi.RewriteLinks(strings.ToUpper),
+			},
+			expected: "=> FIRST.AGMI First\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := agmi.ApplyFilters(agmi.NewScanner(strings.NewReader(tt.input)), tt.filters...)
+			assert.Equal(t, tt.expected, concatTokens(t, ts))
+		})
+	}
+}
+
+func TestReferenceLinks_Streaming(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("Text\n=> gemini://a.example Link\n\n"), iotest.ErrReader(errors.New("read")))
+
+	ts := agmi.ReferenceLinks()(agmi.NewScanner(r))
+	for _, expected := range []string{"Text", "\n", "=> ", "gemini://a.example", " Link"} {
+		if !assert.True(t, ts.Scan()) {
+			return
+		}
+		assert.Equal(t, expected, ts.Token().Text)
+	}
+}
+
+func TestRewriteLinks_LongURI(t *testing.T) {
+	uri := "data:text/plain;base64," + strings.Repeat("QUJD", 100)
+
+	sc := agmi.NewScanner(strings.NewReader("=> " + uri + " Data\n"))
+	sc.Buffer(nil, 32)
+
+	var rewritten []string
+	ts := agmi.RewriteLinks(func(uri string) string {
+		rewritten = append(rewritten, uri)
+		return "data:,"
+	})(sc)
+	assert.Equal(t, "=> data:, Data\n", concatTokens(t, ts))
+	assert.Equal(t, []string{uri}, rewritten)
+}
+
+func TestFilterTokens(t *testing.T) {
+	var calls int
+
+	// Duplicate all text, drop everything else, and add a final token at
+	// the end of the stream.
+	ts := agmi.FilterTokens(agmi.NewScanner(strings.NewReader("a\nb")), func(tok agmi.Token, emit func(agmi.Token)) error {
+		calls++
+		if tok.IsZero() {
+			emit(agmi.Token{Type: agmi.TokenTypeText, Text: "!"})
+			return nil
+		}
+		if tok.Type == agmi.TokenTypeText {
+			emit(tok)
+			emit(tok)
+		}
+		return nil
+	})
+	assert.Equal(t, "aabb!", concatTokens(t, ts))
+	assert.Equal(t, 4, calls)
+}
+
+func TestFilterTokens_Error(t *testing.T) {
+	ts := agmi.FilterTokens(agmi.NewScanner(strings.NewReader("a\nb")), func(tok agmi.Token, emit func(agmi.Token)) error {
+		if tok.Type == agmi.TokenTypeLineBreak {
+			return errors.New("line break")
+		}
+		emit(tok)
+		return nil
+	})
+	assert.True(t, ts.Scan())
+	assert.Equal(t, "a", ts.Token().Text)
+	assert.False(t, ts.Scan())
+	assert.EqualError(t, ts.Err(), "line break")
+}
+
+func concatTokens(t *testing.T, ts agmi.TokenStream) string {
+	t.Helper()
+
+	var sb strings.Builder
+	for ts.Scan() {
+		sb.WriteString(ts.Token().Text)
+	}
+	assert.NoError(t, ts.Err())
+	return sb.String()
+}
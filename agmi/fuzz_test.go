@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+package agmi_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/internal/testsupport"
+)
+
+func FuzzScanner(f *testing.F) {
+	testsupport.AddSeedCorpus(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// A small maximum token size forces the Scanner to split long
+		// tokens.
+		for _, maxTokenSize := range []int{agmi.MaxTokenSize, 16} {
+			var (
+				text bytes.Buffer
+				line = 1
+			)
+
+			sc := agmi.NewScanner(bytes.NewReader(data))
+			sc.Buffer(nil, maxTokenSize)
+			for sc.Scan() {
+				tok := sc.Token()
+				if tok.Text == "" {
+					t.Fatalf("max token size %d: empty %s token at %s", maxTokenSize, tok.Type, tok.Pos)
+				}
+				if tok.Pos.Offset != text.Len() {
+					t.Fatalf("max token size %d: %s token at offset %d; want %d",
+						maxTokenSize, tok.Type, tok.Pos.Offset, text.Len())
+				}
+				if tok.Pos.Line != line {
+					t.Fatalf("max token size %d: %s token at line %d; want %d", maxTokenSize, tok.Type, tok.Pos.Line, line)
+				}
+				text.WriteString(tok.Text)
+				line += strings.Count(tok.Text, "\n")
+			}
+
+			var serr *agmi.SyntaxError
+			if err := sc.Err(); errors.As(err, &serr) && serr.Kind == agmi.SyntaxErrorTokenTooLong {
+				if !bytes.HasPrefix(data, text.Bytes()) {
+					t.Fatalf("max token size %d: tokens are no prefix of the input: %q", maxTokenSize, text.String())
+				}
+				continue
+			} else if err != nil {
+				t.Fatalf("max token size %d: %v", maxTokenSize, err)
+			}
+			if !bytes.Equal(data, text.Bytes()) {
+				t.Fatalf("max token size %d: tokens do not add up to the input: %q", maxTokenSize, text.String())
+			}
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	testsupport.AddSeedCorpus(f)
+	for _, seed := range []string{"* a\n\\\nb", "> a\n\\\nb", "\t\n\n\\\n\\"} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := agmi.Parse(bytes.NewReader(data))
+
+		var serr *agmi.SyntaxError
+		if err != nil && !(errors.As(err, &serr) && serr.Kind == agmi.SyntaxErrorTokenTooLong) {
+			t.Fatalf("parse %q: %v", data, err)
+		}
+	})
+}
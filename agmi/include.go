@@ -0,0 +1,235 @@
+package agmi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// includeDirective matches a comment including another document.
+var includeDirective = regexp.MustCompile(`^<!--\s*include:\s*(\S+)\s*-->$`)
+
+// ExpandIncludes reads an Almost Gemtext document from r, replaces all
+// include directives, and writes the result to w.
+//
+// An include directive is a comment of the form
+//
+//	<!-- include: partials/contact.agmi -->
+//
+// at the beginning of a line. It is replaced by the document it refers to
+// without any trailing newlines. Included documents may include further
+// documents. Directives within pre-formatted text are not expanded.
+//
+// Included documents are read from fsys. name is the path of the document
+// read from r within fsys. Paths starting with a slash are relative to the
+// root of fsys, all other paths are relative to the directory of the
+// including document. Documents outside of fsys cannot be included.
+//
+// ExpandIncludes returns a *SyntaxError pointing to the directive if an
+// included document cannot be read or if documents include each other. The
+// File of the SyntaxError is set if the directive is part of an included
+// document.
+func ExpandIncludes(w io.Writer, r io.Reader, fsys fs.FS, name string) error {
+	const op = "agmi/ExpandIncludes"
+
+	inc := includer{fsys: fsys}
+	if _, err := inc.expand(w, r, path.Clean(name)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ExpandIncludesWithMap works like ExpandIncludes. Additionally, it returns
+// a SourceMap of the expanded document written to w.
+func ExpandIncludesWithMap(w io.Writer, r io.Reader, fsys fs.FS, name string) (*SourceMap, error) {
+	const op = "agmi/ExpandIncludesWithMap"
+
+	inc := includer{fsys: fsys}
+	segs, err := inc.expand(w, r, path.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &SourceMap{segments: segs}, nil
+}
+
+// SourceMap maps positions within a document expanded by
+// ExpandIncludesWithMap to the documents the text at these positions was
+// read from.
+type SourceMap struct {
+	segments []segment // Ordered by their positions in the expanded document.
+}
+
+// segment is a part of an expanded document copied verbatim from a single
+// document.
+type segment struct {
+	pos  Pos    // Start of the segment within the expanded document.
+	file string // Document the segment was copied from. Empty for the expanded document itself.
+	src  Pos    // Start of the segment within file.
+}
+
+// Locate returns the document and the position within that document the
+// text at pos of the expanded document was read from. The document is empty
+// if the text is part of the expanded document itself. Positions that are
+// not valid are returned unchanged.
+func (m *SourceMap) Locate(pos Pos) (string, Pos) {
+	if !pos.IsValid() {
+		return "", pos
+	}
+	i := sort.Search(len(m.segments), func(i int) bool {
+		p := m.segments[i].pos
+		return p.Line > pos.Line || (p.Line == pos.Line && p.Col > pos.Col)
+	})
+	if i == 0 {
+		return "", pos
+	}
+	seg := m.segments[i-1]
+	src := seg.src
+	if pos.Line == seg.pos.Line {
+		src.Col += pos.Col - seg.pos.Col
+	} else {
+		src.Line += pos.Line - seg.pos.Line
+		src.Col = pos.Col
+	}
+	src.Offset += pos.Offset - seg.pos.Offset
+	return seg.file, src
+}
+
+// Translate moves the position of the first SyntaxError in the chain of
+// err from the expanded document to the document it was read from. The
+// File of the SyntaxError is set if that is an included document.
+// SyntaxErrors with a File are left alone. Translate returns err.
+func (m *SourceMap) Translate(err error) error {
+	var serr *SyntaxError
+	if errors.As(err, &serr) && serr.File == "" {
+		serr.File, serr.Pos = m.Locate(serr.Pos)
+	}
+	return err
+}
+
+type includer struct {
+	fsys  fs.FS
+	stack []string // Names of the documents currently being expanded.
+}
+
+// expand writes the expansion of the document name read from r to w. It
+// returns the segments of the expansion relative to its beginning.
+func (inc *includer) expand(w io.Writer, r io.Reader, name string) ([]segment, error) {
+	inc.stack = append(inc.stack, name)
+	defer func() { inc.stack = inc.stack[:len(inc.stack)-1] }()
+
+	var (
+		segs []segment
+		file string // Name of the document in segments, empty for the outermost one.
+		pos  = Pos{Line: 1, Col: 1}
+		cont bool // The next Token continues the last segment.
+	)
+	if len(inc.stack) > 1 {
+		file = name
+	}
+	sc := NewScanner(r)
+	for sc.Scan() {
+		tok := sc.Token()
+		m := includeDirective.FindStringSubmatch(tok.Text)
+		if m == nil || tok.Pos.Col != 1 || (tok.Type != TokenTypeComment && tok.Type != TokenTypeModeline) {
+			if !cont {
+				segs = append(segs, segment{pos: pos, file: file, src: tok.Pos})
+				cont = true
+			}
+			if _, err := io.WriteString(w, tok.Text); err != nil {
+				return nil, err
+			}
+			pos = pos.advance(tok.Text)
+			continue
+		}
+		text, inner, err := inc.include(tok, m[1])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(text); err != nil {
+			return nil, err
+		}
+		for _, seg := range inner {
+			seg.pos = seg.pos.shift(pos)
+			segs = append(segs, seg)
+		}
+		pos = pos.advance(string(text))
+		cont = false
+	}
+	if err := sc.Err(); err != nil {
+		return nil, inc.syntaxError(err)
+	}
+	return segs, nil
+}
+
+// include returns the expanded document target included by the directive
+// tok and its segments.
+func (inc *includer) include(tok Token, target string) ([]byte, []segment, error) {
+	cur := inc.stack[len(inc.stack)-1]
+	if strings.HasPrefix(target, "/") {
+		target = path.Clean(strings.TrimPrefix(target, "/"))
+	} else {
+		target = path.Join(path.Dir(cur), target)
+	}
+	if !fs.ValidPath(target) {
+		return nil, nil, inc.errorAt(tok, SyntaxErrorInclude, fmt.Sprintf("include %s: outside of the site root", target), nil)
+	}
+	for i, name := range inc.stack {
+		if name == target {
+			cycle := strings.Join(append(inc.stack[i:], target), " -> ")
+			return nil, nil, inc.errorAt(tok, SyntaxErrorIncludeCycle, fmt.Sprintf("include cycle: %s", cycle), nil)
+		}
+	}
+
+	data, err := fs.ReadFile(inc.fsys, target)
+	if err != nil {
+		return nil, nil, inc.errorAt(tok, SyntaxErrorInclude, fmt.Sprintf("include %s: %v", target, describeReadError(err)), err)
+	}
+	var buf bytes.Buffer
+	segs, err := inc.expand(&buf, bytes.NewReader(data), target)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := bytes.TrimRight(buf.Bytes(), "\n")
+	// Drop the segments of the trimmed newlines.
+	for len(segs) > 0 && segs[len(segs)-1].pos.Offset >= len(text) {
+		segs = segs[:len(segs)-1]
+	}
+	return text, segs, nil
+}
+
+// errorAt returns a SyntaxError at the position of tok within the document
+// currently being expanded.
+func (inc *includer) errorAt(tok Token, kind SyntaxErrorKind, msg string, err error) error {
+	return inc.syntaxError(&SyntaxError{Pos: tok.Pos, Kind: kind, Msg: msg, Err: err})
+}
+
+// syntaxError sets the File of err if it is a SyntaxError within an
+// included document.
+func (inc *includer) syntaxError(err error) error {
+	var serr *SyntaxError
+	if len(inc.stack) > 1 && errors.As(err, &serr) && serr.File == "" {
+		serr.File = inc.stack[len(inc.stack)-1]
+	}
+	return err
+}
+
+// describeReadError returns the error underlying err if it is an
+// *fs.PathError. The path is already part of the messages of SyntaxErrors
+// created by includer. Missing files are always described by
+// fs.ErrNotExist, regardless of the operating system.
+func describeReadError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fs.ErrNotExist
+	}
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package agmi_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"partials/contact.agmi": {Data: []byte("=> mailto:me@example.com Mail\n<!-- include: license.agmi -->\n")},
+		"partials/license.agmi": {Data: []byte("<!-- vim: set tw=72: -->\nCC-BY-SA\n\n")},
+		"partials/cycle.agmi":   {Data: []byte("<!-- include: /blog/cycle.agmi -->\n")},
+		"blog/cycle.agmi":       {Data: []byte("Text\n<!-- include: ../partials/cycle.agmi -->\n")},
+		"blog/missing.agmi":     {Data: []byte("Text\n\n<!-- include: gone.agmi -->\n")},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		kind     agmi.SyntaxErrorKind
+		errMsg   string
+	}{
+		{
+			name:     "no includes",
+			input:    "# Title\n\n<!-- Just a comment -->\n",
+			expected: "# Title\n\n<!-- Just a comment -->\n",
+		},
+		{
+			name:     "relative and absolute includes",
+			input:    "# Title\n\n<!-- include: ../partials/license.agmi -->\n\n<!-- include: /partials/contact.agmi -->\n",
+			expected: "# Title\n\n<!-- vim: set tw=72: -->\nCC-BY-SA\n\n=> mailto:me@example.com Mail\n<!-- vim: set tw=72: -->\nCC-BY-SA\n",
+		},
+		{
+			name:     "no includes in pre-formatted text",
+			input:    "```\n<!-- include: /partials/license.agmi -->\n```\n",
+			expected: "```\n<!-- include: /partials/license.agmi -->\n```\n",
+		},
+		{
+			name:   "missing document",
+			input:  "Text\n\n<!-- include: gone.agmi -->\nText\n",
+			kind:   agmi.SyntaxErrorInclude,
+			errMsg: "3:1: include blog/gone.agmi: file does not exist",
+		},
+		{
+			name:   "missing document in included document",
+			input:  "<!-- include: /blog/missing.agmi -->\n",
+			kind:   agmi.SyntaxErrorInclude,
+			errMsg: "blog/missing.agmi:3:1: include blog/gone.agmi: file does not exist",
+		},
+		{
+			name:   "outside of the site root",
+			input:  "Text\n<!-- include: ../../secret.agmi -->\nText\n",
+			kind:   agmi.SyntaxErrorInclude,
+			errMsg: "2:1: include ../secret.agmi: outside of the site root",
+		},
+		{
+			name:   "include cycle",
+			input:  "<!-- include: cycle.agmi -->\n",
+			kind:   agmi.SyntaxErrorIncludeCycle,
+			errMsg: "partials/cycle.agmi:1:1: include cycle: blog/cycle.agmi -> partials/cycle.agmi -> blog/cycle.agmi",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			err := agmi.ExpandIncludes(&out, strings.NewReader(tt.input), fsys, "blog/index.agmi")
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, out.String())
+				return
+			}
+			var serr *agmi.SyntaxError
+			if !assert.True(t, errors.As(err, &serr)) {
+				return
+			}
+			assert.Equal(t, tt.kind, serr.Kind)
+			assert.Equal(t, tt.errMsg, serr.Error())
+			if tt.kind == agmi.SyntaxErrorInclude && strings.HasSuffix(tt.errMsg, "file does not exist") {
+				assert.True(t, errors.Is(err, fs.ErrNotExist))
+			}
+		})
+	}
+}
+
+func TestSourceMap_Locate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"footer.agmi":  {Data: []byte("Line 1\nLine 2\n<!-- include: contact.agmi -->\nLine 4\n\n")},
+		"contact.agmi": {Data: []byte("=> mailto:me@example.com Mail\n")},
+	}
+	input := "# Title\nText <!-- include: footer.agmi -->\n<!-- include: footer.agmi --> and more\nLast line\n"
+
+	tests := []struct {
+		name     string
+		pos      agmi.Pos
+		file     string
+		expected agmi.Pos
+	}{
+		{
+			name:     "before the first include",
+			pos:      agmi.Pos{Offset: 10, Line: 2, Col: 3},
+			expected: agmi.Pos{Offset: 10, Line: 2, Col: 3},
+		},
+		{
+			name:     "included document",
+			pos:      agmi.Pos{Offset: 52, Line: 4, Col: 3},
+			file:     "footer.agmi",
+			expected: agmi.Pos{Offset: 9, Line: 2, Col: 3},
+		},
+		{
+			name:     "nested included document",
+			pos:      agmi.Pos{Offset: 60, Line: 5, Col: 4},
+			file:     "contact.agmi",
+			expected: agmi.Pos{Offset: 3, Line: 1, Col: 4},
+		},
+		{
+			name:     "included document after its nested include",
+			pos:      agmi.Pos{Offset: 87, Line: 6, Col: 1},
+			file:     "footer.agmi",
+			expected: agmi.Pos{Offset: 45, Line: 4, Col: 1},
+		},
+		{
+			name:     "text following an include on the same line",
+			pos:      agmi.Pos{Offset: 94, Line: 6, Col: 8},
+			expected: agmi.Pos{Offset: 73, Line: 3, Col: 31},
+		},
+		{
+			name:     "after the last include",
+			pos:      agmi.Pos{Offset: 103, Line: 7, Col: 1},
+			expected: agmi.Pos{Offset: 82, Line: 4, Col: 1},
+		},
+		{
+			name: "invalid position",
+		},
+	}
+
+	var out strings.Builder
+	sm, err := agmi.ExpandIncludesWithMap(&out, strings.NewReader(input), fsys, "index.agmi")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			file, pos := sm.Locate(tt.pos)
+			assert.Equal(t, tt.file, file)
+			assert.Equal(t, tt.expected, pos)
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package agmi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse reads an Almost Gemtext document from r and returns its abstract
+// syntax tree.
+//
+// Parse interprets the document the same way the converters shipped with
+// mnml do. Comments are dropped from the document. Parse does not report
+// violations of the Almost Gemtext specification. Parse returns an error
+// only if reading the document fails.
+func Parse(r io.Reader) (*Document, error) {
+	const op = "agmi/Parse"
+
+	var p parser
+
+	ts := ApplyFilters(NewScanner(r), StripComments())
+	cur := &line{}
+	for ts.Scan() {
+		tok := ts.Token()
+		if isLineEnd(tok) {
+			cur.sep = tok
+			p.lines = append(p.lines, cur)
+			cur = &line{}
+			continue
+		}
+		cur.tokens = append(cur.tokens, tok)
+	}
+	if err := ts.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(cur.tokens) > 0 {
+		p.lines = append(p.lines, cur)
+	}
+
+	doc := &Document{}
+	for p.i < len(p.lines) {
+		if p.lines[p.i].blank() {
+			// Blank lines at the beginning of the document, or lines
+			// holding only an indent. The latter do not start
+			// pre-formatted text.
+			p.i++
+			continue
+		}
+		doc.Blocks = append(doc.Blocks, p.parseBlock())
+	}
+	return doc, nil
+}
+
+// line is a single line of an Almost Gemtext document.
+type line struct {
+	tokens []Token
+	sep    Token // Line break or paragraph separator ending the line. Zero at the end of the document.
+}
+
+// text returns the text of the line starting at the i-th token without any
+// escaping backslashes.
+func (l *line) text(i int) string {
+	var sb strings.Builder
+	for _, tok := range l.tokens[i:] {
+		if tok.Type != TokenTypeEscape {
+			sb.WriteString(tok.Text)
+		}
+	}
+	return sb.String()
+}
+
+// rawText returns the text of the line starting at the i-th token as found in
+// the document. This includes escaping backslashes and the backslash of a
+// hard break ending the line.
+func (l *line) rawText(i int) string {
+	var sb strings.Builder
+	for _, tok := range l.tokens[i:] {
+		sb.WriteString(tok.Text)
+	}
+	if l.sep.Type == TokenTypeHardBreak {
+		sb.WriteString("\\")
+	}
+	return sb.String()
+}
+
+// first returns the first Token of the line, or the zero Token if the line
+// is empty.
+func (l *line) first() Token {
+	if len(l.tokens) == 0 {
+		return Token{}
+	}
+	return l.tokens[0]
+}
+
+// blank returns true if the line is empty or holds only an indent.
+func (l *line) blank() bool {
+	for _, tok := range l.tokens {
+		if !tok.IsBlank() {
+			return false
+		}
+	}
+	return true
+}
+
+// hardBreak returns true if the line ends in a hard break.
+func (l *line) hardBreak() bool {
+	return l.sep.Type == TokenTypeHardBreak
+}
+
+// blankLinesAfter returns the number of blank lines following l.
+func (l *line) blankLinesAfter() int {
+	if l.sep.Type != TokenTypeParSep {
+		return 0
+	}
+	return len(l.sep.Text) - 1
+}
+
+type parser struct {
+	lines []*line
+	i     int // Index of the current line.
+}
+
+// cur returns the current line.
+func (p *parser) cur() *line {
+	return p.lines[p.i]
+}
+
+// continues returns true if the line following the current line belongs to
+// the same paragraph.
+func (p *parser) continues() bool {
+	return p.i+1 < len(p.lines) && (p.cur().sep.Type == TokenTypeLineBreak || p.cur().hardBreak())
+}
+
+func (p *parser) parseBlock() Block {
+	l := p.cur()
+	first := l.first()
+	defer func() { p.i++ }()
+
+	switch first.Type {
+	case TokenTypeModeline:
+		return &Modeline{Start: first.Pos, Text: l.text(0)}
+	case TokenTypeHeadingMod:
+		return &Heading{
+			Start: first.Pos,
+			Level: strings.Count(first.Text, "#"),
+			Text:  strings.Join(p.headingLines(l.text(1)), " "),
+		}
+	case TokenTypePreFmtMod:
+		return p.parsePreFmt()
+	case TokenTypeIndent:
+		if isPreFmtIndent(first.Text) {
+			return p.parsePreFmtByIndent()
+		}
+	case TokenTypeBulletPoint:
+		return p.parseList()
+	case TokenTypeQuoteMod:
+		return p.parseQuote()
+	case TokenTypeLinkMod:
+		return p.parseLink()
+	}
+	par := &Paragraph{Start: first.Pos, Lines: []string{l.text(0)}}
+	for p.continues() {
+		if p.cur().hardBreak() {
+			par.HardBreaks = append(par.HardBreaks, len(par.Lines)-1)
+		}
+		p.i++
+		par.Lines = append(par.Lines, p.cur().text(0))
+	}
+	return par
+}
+
+// headingLines returns s followed by the text of all lines belonging to
+// the same paragraph as the current line.
+func (p *parser) headingLines(s string) []string {
+	lines := []string{s}
+	for p.continues() {
+		p.i++
+		lines = append(lines, p.cur().text(0))
+	}
+	return lines
+}
+
+func (p *parser) parsePreFmt() Block {
+	l := p.cur()
+	pf := &PreFormatted{Start: l.first().Pos, AltText: l.text(1)}
+	for p.i+1 < len(p.lines) {
+		blanks := p.cur().blankLinesAfter()
+		p.i++
+		for j := 0; j < blanks; j++ {
+			pf.Lines = append(pf.Lines, "")
+		}
+		l = p.cur()
+		if l.first().Type == TokenTypePreFmtMod {
+			break
+		}
+		pf.Lines = append(pf.Lines, l.rawText(0))
+	}
+	return pf
+}
+
+func (p *parser) parsePreFmtByIndent() Block {
+	l := p.cur()
+	pf := &PreFormatted{Start: l.first().Pos, Indented: true}
+	for {
+		text := l.rawText(0)
+		if first := l.first(); first.Type == TokenTypeIndent {
+			text = trimPreFmtIndent(first.Text) + l.rawText(1)
+		}
+		pf.Lines = append(pf.Lines, text)
+
+		if p.i+1 >= len(p.lines) {
+			return pf
+		}
+		next := p.lines[p.i+1]
+		if l.sep.Type == TokenTypeParSep && next.first().Type != TokenTypeIndent {
+			return pf
+		}
+		for j := 0; j < l.blankLinesAfter(); j++ {
+			pf.Lines = append(pf.Lines, "")
+		}
+		p.i++
+		l = next
+	}
+}
+
+func (p *parser) parseList() Block {
+	l := p.cur()
+	list := &List{Start: l.first().Pos}
+	item := &ListItem{Start: l.first().Pos, Lines: []string{l.text(1)}}
+	list.Items = append(list.Items, item)
+	for p.continues() {
+		hardBreak := p.cur().hardBreak()
+		p.i++
+		l = p.cur()
+		first := l.first()
+		if first.Type == TokenTypeBulletPoint {
+			item = &ListItem{Start: first.Pos, Lines: []string{l.text(1)}}
+			list.Items = append(list.Items, item)
+			continue
+		}
+		if hardBreak {
+			item.HardBreaks = append(item.HardBreaks, len(item.Lines)-1)
+		}
+		if first.Type == TokenTypeIndent {
+			item.Lines = append(item.Lines, strings.TrimPrefix(first.Text, "  ")+l.text(1))
+			continue
+		}
+		item.Lines = append(item.Lines, l.text(0))
+	}
+	return list
+}
+
+func (p *parser) parseQuote() Block {
+	l := p.cur()
+	q := &Quote{Start: l.first().Pos, Lines: []string{l.text(1)}}
+	for p.continues() {
+		if p.cur().hardBreak() {
+			q.HardBreaks = append(q.HardBreaks, len(q.Lines)-1)
+		}
+		p.i++
+		l = p.cur()
+		if l.first().Type == TokenTypeQuoteMod {
+			q.Lines = append(q.Lines, l.text(1))
+			continue
+		}
+		q.Lines = append(q.Lines, l.text(0))
+	}
+	return q
+}
+
+func (p *parser) parseLink() Block {
+	l := p.cur()
+	link := &Link{Start: l.first().Pos}
+
+	var uri, text strings.Builder
+	for _, tok := range l.tokens[1:] {
+		if tok.Type == TokenTypeLinkURI {
+			uri.WriteString(tok.Text)
+			continue
+		}
+		text.WriteString(tok.Text)
+	}
+	link.URI = uri.String()
+	link.Text = strings.TrimLeft(text.String(), " \t")
+	return link
+}
+
+func isPreFmtIndent(s string) bool {
+	return s == "    " || s == "\t"
+}
+
+// trimPreFmtIndent removes the indentation identifying pre-formatted text
+// from the indent s.
+func trimPreFmtIndent(s string) string {
+	if strings.HasPrefix(s, "\t") {
+		return strings.TrimPrefix(s, "\t")
+	}
+	return strings.TrimPrefix(s, "    ")
+}
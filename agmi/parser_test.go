@@ -0,0 +1,245 @@
+package agmi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"<!-- vim: set tw=72: -->",
+		"",
+		"# Title",
+		"",
+		"A paragraph",
+		"spanning two lines.",
+		"",
+		"* First item",
+		"  continued",
+		"* Second item",
+		"",
+		"> A quote",
+		"> continued",
+		"",
+		"```go",
+		"func main() {",
+		"",
+		"}",
+		"```",
+		"",
+		"    indented",
+		"",
+		"    \tmore",
+		"",
+		"=> gemini://example.com Example",
+		"=> gemini://example.org",
+	}, "\n")
+	expected := &agmi.Document{
+		Blocks: []agmi.Block{
+			&agmi.Modeline{
+				Start: agmi.Pos{Offset: 0, Line: 1, Col: 1},
+				Text:  "<!-- vim: set tw=72: -->",
+			},
+			&agmi.Heading{
+				Start: agmi.Pos{Offset: 26, Line: 3, Col: 1},
+				Level: 1,
+				Text:  "Title",
+			},
+			&agmi.Paragraph{
+				Start: agmi.Pos{Offset: 35, Line: 5, Col: 1},
+				Lines: []string{"A paragraph", "spanning two lines."},
+			},
+			&agmi.List{
+				Start: agmi.Pos{Offset: 68, Line: 8, Col: 1},
+				Items: []*agmi.ListItem{
+					{
+						Start: agmi.Pos{Offset: 68, Line: 8, Col: 1},
+						Lines: []string{"First item", "continued"},
+					},
+					{
+						Start: agmi.Pos{Offset: 93, Line: 10, Col: 1},
+						Lines: []string{"Second item"},
+					},
+				},
+			},
+			&agmi.Quote{
+				Start: agmi.Pos{Offset: 108, Line: 12, Col: 1},
+				Lines: []string{"A quote", "continued"},
+			},
+			&agmi.PreFormatted{
+				Start:   agmi.Pos{Offset: 131, Line: 15, Col: 1},
+				AltText: "go",
+				Lines:   []string{"func main() {", "", "}"},
+			},
+			&agmi.PreFormatted{
+				Start:    agmi.Pos{Offset: 159, Line: 21, Col: 1},
+				Indented: true,
+				Lines:    []string{"indented", "", "\tmore"},
+			},
+			&agmi.Link{
+				Start: agmi.Pos{Offset: 184, Line: 25, Col: 1},
+				URI:   "gemini://example.com",
+				Text:  "Example",
+			},
+			&agmi.Link{
+				Start: agmi.Pos{Offset: 216, Line: 26, Col: 1},
+				URI:   "gemini://example.org",
+			},
+		},
+	}
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, expected, doc)
+}
+
+func TestParse_HardBreaks(t *testing.T) {
+	input := strings.Join([]string{
+		`Roses are red,\`,
+		`violets are blue,`,
+		`and so on.`,
+		``,
+		`* Jane Doe\`,
+		`  Springfield`,
+		``,
+		`> A quote\`,
+		`> continued`,
+		``,
+		`# A heading\`,
+		`continued`,
+		``,
+		"```",
+		`code\`,
+		"```",
+	}, "\n")
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if !assert.NoError(t, err) || !assert.Len(t, doc.Blocks, 5) {
+		return
+	}
+
+	par := doc.Blocks[0].(*agmi.Paragraph)
+	assert.Equal(t, []int{0}, par.HardBreaks)
+	assert.Equal(t, "Roses are red,\nviolets are blue, and so on.", par.Text())
+
+	item := doc.Blocks[1].(*agmi.List).Items[0]
+	assert.Equal(t, []int{0}, item.HardBreaks)
+	assert.Equal(t, "Jane Doe\nSpringfield", item.Text())
+
+	quote := doc.Blocks[2].(*agmi.Quote)
+	assert.Equal(t, []int{0}, quote.HardBreaks)
+	assert.Equal(t, "A quote\ncontinued", quote.Text())
+
+	assert.Equal(t, "A heading continued", doc.Blocks[3].(*agmi.Heading).Text)
+
+	assert.Equal(t, []string{`code\`}, doc.Blocks[4].(*agmi.PreFormatted).Lines)
+}
+
+func TestParse_Backslashes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []agmi.Block
+	}{
+		{
+			name:  "backslash on a line of its own in a list item",
+			input: "* a\n\\\nb",
+			expected: []agmi.Block{
+				&agmi.List{
+					Start: agmi.Pos{Offset: 0, Line: 1, Col: 1},
+					Items: []*agmi.ListItem{
+						{Start: agmi.Pos{Offset: 0, Line: 1, Col: 1}, Lines: []string{"a", "\\", "b"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "backslash on a line of its own in a quote",
+			input: "> a\n\\\nb",
+			expected: []agmi.Block{
+				&agmi.Quote{Start: agmi.Pos{Offset: 0, Line: 1, Col: 1}, Lines: []string{"a", "\\", "b"}},
+			},
+		},
+		{
+			name:  "backslashes on lines of their own after a blank indented line",
+			input: "\t\n\n\\\n\\",
+			expected: []agmi.Block{
+				&agmi.Paragraph{Start: agmi.Pos{Offset: 3, Line: 3, Col: 1}, Lines: []string{"\\", "\\"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := agmi.Parse(strings.NewReader(tt.input))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, doc.Blocks)
+			}
+		})
+	}
+}
+
+func TestParse_BlankIndentedLines(t *testing.T) {
+	input := "\t\n\nText\n\n    \n\n    code\n    \n    more"
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []agmi.Block{
+		&agmi.Paragraph{Start: agmi.Pos{Offset: 3, Line: 3, Col: 1}, Lines: []string{"Text"}},
+		&agmi.PreFormatted{
+			Start:    agmi.Pos{Offset: 15, Line: 7, Col: 1},
+			Lines:    []string{"code", "", "more"},
+			Indented: true,
+		},
+	}, doc.Blocks)
+}
+
+func TestParse_Escapes(t *testing.T) {
+	input := strings.Join([]string{
+		`\* not a list item`,
+		`\=> not a link`,
+		``,
+		"```",
+		`\* kept`,
+		"```",
+	}, "\n")
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if !assert.NoError(t, err) || !assert.Len(t, doc.Blocks, 2) {
+		return
+	}
+	assert.Equal(t, []string{"* not a list item", "=> not a link"}, doc.Blocks[0].(*agmi.Paragraph).Lines)
+	assert.Equal(t, []string{`\* kept`}, doc.Blocks[1].(*agmi.PreFormatted).Lines)
+}
+
+func TestParse_Comments(t *testing.T) {
+	input := strings.Join([]string{
+		`First line`,
+		`<!-- A comment`,
+		`spanning lines -->`,
+		`second line`,
+		``,
+		`<!-- Another comment -->`,
+		``,
+		"```",
+		`<!-- kept -->`,
+		"```",
+		`Last line`,
+	}, "\n")
+
+	doc, err := agmi.Parse(strings.NewReader(input))
+	if !assert.NoError(t, err) || !assert.Len(t, doc.Blocks, 3) {
+		return
+	}
+	assert.Equal(t, []string{"First line", "second line"}, doc.Blocks[0].(*agmi.Paragraph).Lines)
+	assert.Equal(t, []string{"<!-- kept -->"}, doc.Blocks[1].(*agmi.PreFormatted).Lines)
+	assert.Equal(t, []string{"Last line"}, doc.Blocks[2].(*agmi.Paragraph).Lines)
+}
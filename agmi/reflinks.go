@@ -0,0 +1,252 @@
+package agmi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// RefMarker matches a reference to a link within text. The first
+	// submatch is the name of the reference.
+	RefMarker = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+
+	// RefDefinition matches the text of a link defining a reference. The
+	// first submatch is the name of the reference, the second the remaining
+	// text of the link. Only links with an URI define references.
+	RefDefinition = regexp.MustCompile(`^\s*\[\^([^\]\s]+)\]\s*(.*)$`)
+)
+
+// ReferenceLinks numbers references to links.
+//
+// A reference is a name enclosed in [^ and ], e.g. [^gemtext]. A link whose
+// text starts with a reference defines the link the reference refers to:
+//
+//	=> gemini://gemini.circumlunar.space/docs/gemtext.gmi [^gemtext] Gemtext
+//
+// ReferenceLinks numbers the references in the order of their first use
+// and replaces them with their number, e.g. [1]. The definitions are
+// removed. Instead, a link labelled with the number is placed after the
+// paragraph using the reference for the first time. Definitions which are
+// never used are kept as ordinary links, references which are never
+// defined are retained as is.
+//
+// ReferenceLinks passes Tokens on as they are read until it encounters the
+// first reference or definition. From there on it has to hold back all
+// Tokens until the end of the document is reached.
+func ReferenceLinks() Filter {
+	return func(ts TokenStream) TokenStream {
+		var (
+			lt        = newLineTracker()
+			held      []Token // Line end and link held back until the link is known not to be a definition.
+			inLink    bool
+			buffering bool
+			toks      []Token // Tokens following the first reference or definition.
+		)
+
+		return FilterTokens(ts, func(tok Token, emit func(Token)) error {
+			defer lt.update(tok)
+
+			switch {
+			case buffering && !tok.IsZero():
+				toks = append(toks, tok)
+				return nil
+			case buffering:
+				for _, t := range numberReferences(toks) {
+					emit(t)
+				}
+				return nil
+			case tok.Type == TokenTypeText && !lt.preFmt && RefMarker.MatchString(tok.Text):
+				buffering = true
+				toks = append(held, tok)
+				return nil
+			case tok.Type == TokenTypeLinkMod && lt.isBlockStart():
+				inLink = true
+			case inLink && !isLineEnd(tok) && !tok.IsZero():
+			default:
+				for _, t := range held {
+					emit(t)
+				}
+				held, inLink = held[:0], false
+				if !isLineEnd(tok) {
+					if !tok.IsZero() {
+						emit(tok)
+					}
+					return nil
+				}
+			}
+			held = append(held, tok)
+			return nil
+		})
+	}
+}
+
+// refLink is a link defined for a reference.
+type refLink struct {
+	uri   string
+	title string
+}
+
+// findReferenceLinks returns the links defined for the references used in
+// toks. It marks the Tokens of all lines defining such a link in drop.
+func findReferenceLinks(toks []Token) (map[string]refLink, []bool) {
+	var (
+		used  = usedReferences(toks)
+		lt    = newLineTracker()
+		links = make(map[string]refLink)
+		drop  = make([]bool, len(toks))
+	)
+
+	for i := 0; i < len(toks); i++ {
+		if toks[i].Type != TokenTypeLinkMod || !lt.isBlockStart() {
+			lt.update(toks[i])
+			continue
+		}
+		var uri strings.Builder
+		j := i + 1
+		for ; j < len(toks) && toks[j].Type == TokenTypeLinkURI; j++ {
+			uri.WriteString(toks[j].Text)
+		}
+		var text strings.Builder
+		for ; j < len(toks) && !isLineEnd(toks[j]); j++ {
+			text.WriteString(toks[j].Text)
+		}
+		m := RefDefinition.FindStringSubmatch(text.String())
+		if m != nil && uri.Len() > 0 && used[m[1]] {
+			if _, ok := links[m[1]]; !ok {
+				links[m[1]] = refLink{uri: uri.String(), title: m[2]}
+			}
+			for k := i; k < j; k++ {
+				drop[k] = true
+			}
+		}
+		for ; i < j; i++ {
+			lt.update(toks[i])
+		}
+		i--
+	}
+	return links, drop
+}
+
+// usedReferences returns the names of all references used in the text of
+// toks outside of links and pre-formatted text.
+func usedReferences(toks []Token) map[string]bool {
+	var (
+		lt     = newLineTracker()
+		used   = make(map[string]bool)
+		inLink bool
+	)
+
+	for _, tok := range toks {
+		switch {
+		case tok.Type == TokenTypeLinkMod && lt.isBlockStart():
+			inLink = true
+		case isLineEnd(tok):
+			inLink = false
+		case tok.Type == TokenTypeText && !inLink && !lt.preFmt:
+			for _, m := range RefMarker.FindAllStringSubmatch(tok.Text, -1) {
+				used[m[1]] = true
+			}
+		}
+		lt.update(tok)
+	}
+	return used
+}
+
+// numberReferences replaces the references in toks with their numbers and
+// moves the links defined for them after the paragraphs using them first.
+func numberReferences(toks []Token) []Token {
+	var (
+		links, drop = findReferenceLinks(toks)
+		lt          = newLineTracker()
+		numbers     = make(map[string]int)
+		pending     []string // References to place after the current paragraph.
+		out         = make([]Token, 0, len(toks))
+		lineEnd     Token // Line end held back until the next line is known.
+		skipping    bool  // Skipping the line of a definition.
+		inLink      bool
+	)
+
+	// placeLinks appends the links of all pending references.
+	placeLinks := func(pos Pos) {
+		out = append(out, Token{Type: TokenTypeParSep, Pos: pos, Text: "\n\n"})
+		for i, name := range pending {
+			if i > 0 {
+				out = append(out, Token{Type: TokenTypeLineBreak, Pos: pos, Text: "\n"})
+			}
+			text := fmt.Sprintf(" [%d]", numbers[name])
+			if title := links[name].title; title != "" {
+				text += " " + title
+			}
+			out = append(out,
+				Token{Type: TokenTypeLinkMod, Pos: pos, Text: "=> "},
+				Token{Type: TokenTypeLinkURI, Pos: pos, Text: links[name].uri},
+				Token{Type: TokenTypeText, Pos: pos, Text: text},
+			)
+		}
+		pending = nil
+	}
+
+	// flushLineEnd appends the line end held back, preceded by the links of
+	// all pending references if it ends a paragraph.
+	flushLineEnd := func() {
+		if lineEnd.IsZero() {
+			return
+		}
+		if lineEnd.Type == TokenTypeParSep && len(pending) > 0 && !lt.preFmt {
+			placeLinks(lineEnd.Pos)
+		}
+		out = append(out, lineEnd)
+		lineEnd = Token{}
+	}
+
+	for i, tok := range toks {
+		switch {
+		case drop[i]:
+			skipping = true
+		case skipping && isLineEnd(tok):
+			if tok.Type == TokenTypeParSep && lineEnd.Type == TokenTypeLineBreak {
+				lineEnd = Token{Type: TokenTypeParSep, Pos: lineEnd.Pos, Text: "\n\n"}
+			}
+		case isLineEnd(tok):
+			flushLineEnd()
+			lineEnd = tok
+		default:
+			skipping = false
+			flushLineEnd()
+			if tok.Type == TokenTypeLinkMod && lt.isBlockStart() {
+				inLink = true
+			}
+			if tok.Type == TokenTypeText && !inLink && !lt.preFmt {
+				tok.Text = RefMarker.ReplaceAllStringFunc(tok.Text, func(marker string) string {
+					name := RefMarker.FindStringSubmatch(marker)[1]
+					if _, ok := links[name]; !ok {
+						return marker
+					}
+					if _, ok := numbers[name]; !ok {
+						numbers[name] = len(numbers) + 1
+						pending = append(pending, name)
+					}
+					return fmt.Sprintf("[%d]", numbers[name])
+				})
+			}
+			out = append(out, tok)
+		}
+		if isLineEnd(tok) {
+			inLink = false
+		}
+		lt.update(tok)
+	}
+
+	if len(pending) > 0 && !lt.preFmt {
+		placeLinks(lineEnd.Pos)
+	}
+	if skipping && lineEnd.Type == TokenTypeParSep {
+		// Do not end the document with a blank line left by a definition.
+		lineEnd = Token{Type: TokenTypeLineBreak, Pos: lineEnd.Pos, Text: "\n"}
+	}
+	if !lineEnd.IsZero() {
+		out = append(out, lineEnd)
+	}
+	return out
+}
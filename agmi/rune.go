@@ -4,6 +4,9 @@ var (
 	isVSpace    = isRune('\n') // We currently don't allow for '\r'
 	isHSpace    = isRune(' ', '\t')
 	notIsHSpace = notIsRune(' ', '\t')
+	isSpace     = isRune(' ', '\t', '\n')
+	notIsVSpace = notIsRune('\n')
+	isEscapable = isRune('*', '>', '=', '#', '`', '<', '\\')
 )
 
 func isRune(rs ...rune) func(rune) bool {
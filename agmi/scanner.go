@@ -0,0 +1,711 @@
+package agmi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// TokenType defines the type of an Almost Gemtext Token.
+type TokenType int
+
+const (
+	tokenTypeUnknown TokenType = iota
+
+	// TokenTypeModeline marks the Token as a modeline.
+	TokenTypeModeline
+
+	// TokenTypeLineBreak identifies the token as a simple line break.
+	TokenTypeLineBreak
+
+	// TokenTypeParSep identifies the token as a separator of paragraphs.
+	TokenTypeParSep
+
+	// TokenTypeQuoteMod identifies the next line of text as being a quote or
+	// part of a quote.
+	//
+	// Consecutive occurrences of TokenTypeQuoteMod are to be treated as
+	// interspersed with empty lines of text.
+	TokenTypeQuoteMod
+
+	// TokenTypePreFmtMod signals either the beginning or the end of
+	// pre-formatted text.
+	TokenTypePreFmtMod
+
+	// TokenTypeLinkMod signals the beginning of a link.
+	TokenTypeLinkMod
+
+	// TokenTypeLinkURI is the URI part of a link.
+	TokenTypeLinkURI
+
+	// TokenTypeHeadingMod signals the beginning of a heading. The Token's
+	// text contains all leading pound characters and any horizontal space
+	// following them.
+	TokenTypeHeadingMod
+
+	// TokenTypeBulletPoint signals that the token is the bullet point
+	// belonging to a list item. The immediately following Tokens of
+	// TokenTypeLine and TokenTypeIndent constitute the list item for this
+	// bullet point.
+	TokenTypeBulletPoint
+
+	// TokenTypeIndent signals that the Token's text was used to indent the
+	// text of the following token.
+	TokenTypeIndent
+
+	// TokenTypeText marks the token as plain text. Tokens that occurred
+	// earlier may have an influence on how the line is treated.
+	TokenTypeText
+
+	// TokenTypeHardBreak is a line break which must be retained when lines
+	// are joined. Its text is a backslash followed by a newline. It replaces
+	// the Token of TokenTypeLineBreak at the end of the line.
+	//
+	// A backslash at the end of a line followed by an empty line, or by the
+	// end of the document, is plain text.
+	TokenTypeHardBreak
+
+	// TokenTypeEscape is a backslash preceding a character which would
+	// otherwise start a heading, list item, quote, link, pre-formatted text,
+	// or modeline. The remainder of the line is plain text. Converters drop
+	// the backslash.
+	TokenTypeEscape
+
+	// TokenTypeComment is a comment starting with <!-- and ending with the
+	// next -->. Comments may start anywhere within a line of text and may
+	// span several lines. Converters drop comments.
+	//
+	// A comment at the beginning of the last line of a document, or closed
+	// within the first line, is a modeline instead. Comments are not
+	// recognized within pre-formatted text, on lines indented by a tab or at
+	// least four spaces, or within link URIs. Long comments are split into
+	// several consecutive Tokens of type TokenTypeComment.
+	TokenTypeComment
+)
+
+//go:generate stringer -type TokenType -trimprefix TokenType -output tokentype.string.go
+
+// Token represents a token of an Almost Gemtext document.
+type Token struct {
+	Type TokenType // Type of the token
+	Text string    // Token text as read from the input.
+	Pos  Pos       // Position of the first byte of the token in the input.
+}
+
+// Pos describes a position within an Almost Gemtext document.
+//
+// Lines and columns start at 1. Columns are counted in bytes. The zero value
+// of Pos is not a valid position.
+type Pos struct {
+	Offset int // Offset in bytes, starting at 0.
+	Line   int // Line number, starting at 1.
+	Col    int // Column number, starting at 1.
+}
+
+// IsValid returns true if pos is a valid position.
+func (pos Pos) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns the position formatted as line:column.
+func (pos Pos) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Col)
+}
+
+// advance returns the position immediately following s, assuming s starts
+// at pos.
+func (pos Pos) advance(s string) Pos {
+	for i := 0; i < len(s); i++ {
+		pos.Offset++
+		pos.Col++
+		if s[i] == '\n' {
+			pos.Line++
+			pos.Col = 1
+		}
+	}
+	return pos
+}
+
+// shift returns pos, a position within a text starting at base, as a
+// position within the text surrounding it.
+func (pos Pos) shift(base Pos) Pos {
+	if pos.Line == 1 {
+		pos.Col += base.Col - 1
+	}
+	pos.Line += base.Line - 1
+	pos.Offset += base.Offset
+	return pos
+}
+
+// IsZero returns true if this Token equals the zero value of the Token type.
+func (tok Token) IsZero() bool {
+	return tok.Type == tokenTypeUnknown && tok.Text == ""
+}
+
+// IsBlank returns true if tok is a line break, a paragraph separator, or an
+// indent. A line consisting of blank Tokens only is a blank line.
+func (tok Token) IsBlank() bool {
+	switch tok.Type {
+	case TokenTypeLineBreak, TokenTypeParSep, TokenTypeIndent:
+		return true
+	default:
+		return false
+	}
+}
+
+// MaxTokenSize is the default maximum size of a single Token in bytes.
+//
+// See the Buffer method of Scanner for details.
+const MaxTokenSize = bufio.MaxScanTokenSize
+
+// Scanner scans a single Almost Gemtext document and creates a stream of
+// tokens for further processing.
+//
+// The zero value of Scanner is not usable. Use NewScanner to obtain a working
+// instance.
+type Scanner struct {
+	scanner *bufio.Scanner
+	state   bufio.SplitFunc
+	token   Token
+	pos     Pos  // Position of the token following the current token.
+	content bool // Anything but line breaks was scanned.
+	preFmt  bool // The Scanner is within text enclosed in ```.
+	code    bool // The current line is indented like pre-formatted text.
+
+	maxTokenSize int
+}
+
+// NewScanner creates a new scanner for an Almost Gemtext document.
+//
+// The document is read from r.
+func NewScanner(r io.Reader) *Scanner {
+	var sc Scanner
+
+	sc.scanner = bufio.NewScanner(r)
+	sc.scanner.Split(sc.splitFunc)
+	sc.state = sc.scanLine
+	sc.pos = Pos{Line: 1, Col: 1}
+	sc.maxTokenSize = MaxTokenSize
+
+	return &sc
+}
+
+// Buffer sets the initial buffer to use when scanning and the maximum size
+// of a single Token.
+//
+// Text, link URIs, and modelines longer than max are split into several
+// consecutive tokens of the same type. Any other token exceeding max causes
+// the Scanner to stop with an error.
+//
+// Buffer behaves like the Buffer method of bufio.Scanner. It panics if it is
+// called after scanning has started.
+func (sc *Scanner) Buffer(buf []byte, max int) {
+	if max < utf8.UTFMax {
+		max = utf8.UTFMax
+	}
+	sc.scanner.Buffer(buf, max)
+	sc.maxTokenSize = max
+	if cap(buf) > max {
+		sc.maxTokenSize = cap(buf)
+	}
+}
+
+// Scan advances the input until it was able to read a Token or an error occurs.
+//
+// Scan returns false if the scan stops. This may be the case if an error
+// occurred or if the end of the input was reached. In the case of an error the
+// Err method contains the error that occurred.
+func (sc *Scanner) Scan() bool {
+	sc.token = Token{} // reset token detected by last scan
+	if !sc.scanner.Scan() {
+		return false
+	}
+	sc.token.Text = sc.scanner.Text()
+	sc.token.Pos = sc.pos
+	sc.pos = sc.pos.advance(sc.token.Text)
+	if sc.token.Type != TokenTypeLineBreak && sc.token.Type != TokenTypeParSep {
+		sc.content = true
+	}
+	switch {
+	case sc.token.Type == TokenTypeIndent && sc.token.Pos.Col == 1:
+		sc.code = sc.token.Text[0] == '\t' || len(sc.token.Text) >= 4
+	case isLineEnd(sc.token):
+		sc.code = false
+	}
+	return true
+}
+
+// Token returns the Token read during the previous call to Scan.
+func (sc *Scanner) Token() Token {
+	return sc.token
+}
+
+// Err returns any error that occurred during the last call to scan.
+//
+// If the input was completely consumed by Scan Err returns nil instead of
+// io.EOF.
+func (sc *Scanner) Err() error {
+	err := sc.scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return &SyntaxError{
+			Pos:  sc.pos,
+			Kind: SyntaxErrorTokenTooLong,
+			Msg:  fmt.Sprintf("token exceeds maximum size of %d bytes", sc.maxTokenSize),
+			Err:  err,
+		}
+	}
+	return err
+}
+
+func (sc *Scanner) splitFunc(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	return sc.state(data, atEOF)
+}
+
+func (sc *Scanner) scanLine(data []byte, atEOF bool) (int, []byte, error) {
+	switch data[0] {
+	case '<':
+		return sc.goToState(sc.scanModeLine, data, atEOF)
+	case '>':
+		return sc.goToState(sc.scanQuote, data, atEOF)
+	case '\n':
+		return sc.goToState(sc.scanParSep, data, atEOF)
+	case '`':
+		return sc.goToState(sc.scanFmtMod, data, atEOF)
+	case ' ', '\t':
+		return sc.goToState(sc.scanIndent, data, atEOF)
+	case '*':
+		return sc.goToState(sc.scanBulletPoint, data, atEOF)
+	case '=':
+		return sc.goToState(sc.scanLinkMod, data, atEOF)
+	case '#':
+		return sc.goToState(sc.scanHeadingMod, data, atEOF)
+	case '\\':
+		return sc.goToState(sc.scanEscape, data, atEOF)
+	default:
+		// Cannot decide on the type of line. Treat it as text.
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+}
+
+func (sc *Scanner) scanModeLine(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 4 {
+		if atEOF {
+			// The current line is not a modeline, since it does not contain
+			// enough data for an opening modeline identifier and there is no more
+			// input left. Treat it as arbitrary text.
+			return sc.goToState(sc.scanText, data, atEOF)
+		}
+		return 0, nil, nil
+	}
+	if string(data[0:4]) != "<!--" {
+		// The line did not start with a modeline identifier. It must be an
+		// arbitrary text
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+	if sc.pos.Col != 1 || sc.preFmt {
+		// Modelines and comments have to start at the beginning of a line
+		// outside of pre-formatted text.
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+	if !sc.content {
+		// First line of the document. Leading blank lines do not count.
+		return sc.scanFirstLine(data, atEOF)
+	}
+	return sc.scanLastLine(data, atEOF)
+}
+
+// scanFirstLine scans a modeline if the <!-- at the beginning of data is
+// closed on the same line, or if the line is the only line of the document.
+// Otherwise the line starts a comment, which may span several lines.
+func (sc *Scanner) scanFirstLine(data []byte, atEOF bool) (int, []byte, error) {
+	line := data
+	i := bytes.IndexFunc(data, isVSpace)
+	if i >= 0 {
+		line = data[:i]
+	}
+	switch {
+	case bytes.Contains(line[len("<!--"):], []byte("-->")):
+		return sc.scanModeLineText(data, atEOF)
+	case i >= 0 || atEOF:
+		return sc.scanLastLine(data, atEOF)
+	case len(data) >= sc.maxTokenSize:
+		// The line is too long to find its end. Treat it as a modeline
+		// split into several Tokens.
+		return sc.scanModeLineText(data, atEOF)
+	default:
+		return 0, nil, nil // Read more data
+	}
+}
+
+// scanLastLine scans a modeline if the current line is the last line of the
+// document, i.e. if it is followed only by line breaks. Otherwise the line
+// starts a comment.
+func (sc *Scanner) scanLastLine(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexFunc(data, isVSpace)
+	if i >= 0 {
+		i = bytes.IndexFunc(data[i:], notIsVSpace)
+	}
+	switch {
+	case i >= 0:
+		return sc.goToState(sc.scanComment, data, atEOF)
+	case atEOF:
+		return sc.scanModeLineText(data, atEOF)
+	case len(data) >= sc.maxTokenSize:
+		// The line is too long to find out if it is the last one.
+		return sc.goToState(sc.scanComment, data, atEOF)
+	default:
+		return 0, nil, nil // Read more data
+	}
+}
+
+func (sc *Scanner) scanModeLineText(data []byte, atEOF bool) (int, []byte, error) {
+	return sc.scanChunked(data, atEOF, TokenTypeModeline, sc.scanModeLineText, sc.scanLine, isVSpace)
+}
+
+// scanComment scans a comment starting with the <!-- at the beginning of
+// data.
+func (sc *Scanner) scanComment(data []byte, atEOF bool) (int, []byte, error) {
+	return sc.scanCommentText(data, atEOF, len("<!--"))
+}
+
+// scanCommentText scans the text of a comment up to and including the
+// closing -->. The first offset bytes of data were already found to belong
+// to the comment.
+//
+// Comments without a closing --> extend to the end of the document.
+func (sc *Scanner) scanCommentText(data []byte, atEOF bool, offset int) (int, []byte, error) {
+	if i := bytes.Index(data[offset:], []byte("-->")); i >= 0 {
+		i += offset + 3
+		sc.tokenFound(TokenTypeComment, sc.scanAfterComment)
+		return i, data[:i], nil
+	}
+	if atEOF {
+		sc.tokenFound(TokenTypeComment, sc.scanLine)
+		return len(data), data, nil
+	}
+	if len(data) < sc.maxTokenSize {
+		return 0, nil, nil // Read more data
+	}
+	// Split the comment into chunks. Retain the last two bytes as they
+	// might be the beginning of the closing -->.
+	i := sc.maxTokenSize - 2
+	for i > 0 && !utf8.RuneStart(data[i]) {
+		i--
+	}
+	if i == 0 {
+		i = sc.maxTokenSize - 2
+	}
+	sc.tokenFound(TokenTypeComment, func(data []byte, atEOF bool) (int, []byte, error) {
+		return sc.scanCommentText(data, atEOF, 0)
+	})
+	return i, data[:i], nil
+}
+
+// scanAfterComment scans the remainder of the line following a comment as
+// text.
+func (sc *Scanner) scanAfterComment(data []byte, atEOF bool) (int, []byte, error) {
+	if isVSpace(rune(data[0])) {
+		return sc.goToState(sc.scanLine, data, atEOF)
+	}
+	return sc.goToState(sc.scanText, data, atEOF)
+}
+
+func (sc *Scanner) scanParSep(data []byte, atEOF bool) (int, []byte, error) {
+	// Assume we are dealing with a paragraph separator and find the index
+	// of the first rune which is not a line break
+	sc.tokenFound(TokenTypeParSep, sc.scanLine)
+	i, tok, err := sc.scanFunc(data, atEOF, notIsRune('\n'))
+	if err != nil {
+		// Not wrapping the error is ok. We are only interested in the error
+		// returned by the scanner.
+		return i, tok, err
+	}
+	if i == 0 {
+		// Read more data
+		return 0, nil, nil
+	}
+	if i == 1 {
+		// Our assumption was wrong. The input contained only a normal line
+		// break.
+		sc.tokenFound(TokenTypeLineBreak, sc.scanLine)
+	}
+	return i, tok, nil
+}
+
+func (sc *Scanner) scanText(data []byte, atEOF bool) (int, []byte, error) {
+	return sc.scanTextFrom(data, atEOF, 0)
+}
+
+// scanTextFrom scans text which may contain a comment starting at index
+// from of data or later.
+func (sc *Scanner) scanTextFrom(data []byte, atEOF bool, from int) (int, []byte, error) {
+	if i := sc.inlineComment(data, from); i >= 0 {
+		return sc.scanInlineComment(data, atEOF, i)
+	}
+	// A backslash at the end of the line followed by a single line break
+	// is a hard break. A line holding only a backslash is text, and so is an
+	// escaped backslash.
+	if i := bytes.IndexFunc(data, isVSpace); i > from && data[i-1] == '\\' && (i > 1 || sc.pos.Col > 1) {
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil // Read more data
+		}
+		if i+1 < len(data) && !isVSpace(rune(data[i+1])) {
+			if i == 1 {
+				return sc.goToState(sc.scanHardBreak, data, atEOF)
+			}
+			sc.tokenFound(TokenTypeText, sc.scanHardBreak)
+			return i - 1, data[:i-1], nil
+		}
+	}
+	return sc.scanChunked(data, atEOF, TokenTypeText, sc.scanText, sc.scanLine, isVSpace)
+}
+
+func (sc *Scanner) scanEscape(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 2 {
+		if atEOF {
+			return sc.goToState(sc.scanText, data, atEOF)
+		}
+		return 0, nil, nil // Read more data
+	}
+	if !isEscapable(rune(data[1])) {
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+	sc.tokenFound(TokenTypeEscape, func(data []byte, atEOF bool) (int, []byte, error) {
+		// The escaped character does not start a comment.
+		return sc.scanTextFrom(data, atEOF, 1)
+	})
+	return 1, data[:1], nil
+}
+
+// inlineComment returns the index of the first <!-- within the current
+// line of data, or of the backslash escaping it. The first from bytes of
+// data are ignored. inlineComment returns -1 if the line does not contain a
+// comment, or if the line is pre-formatted text or indented like it.
+func (sc *Scanner) inlineComment(data []byte, from int) int {
+	if sc.preFmt || sc.code || from >= len(data) {
+		return -1
+	}
+	line := data[from:]
+	if i := bytes.IndexFunc(line, isVSpace); i >= 0 {
+		line = line[:i]
+	}
+	i := bytes.Index(line, []byte("<!--"))
+	if i < 0 {
+		return -1
+	}
+	i += from
+	if i > from && data[i-1] == '\\' {
+		i--
+	}
+	return i
+}
+
+// scanInlineComment scans the text preceding the comment found at index i
+// of data by inlineComment. If i is zero it scans the comment or the
+// backslash escaping it.
+func (sc *Scanner) scanInlineComment(data []byte, atEOF bool, i int) (int, []byte, error) {
+	switch {
+	case i > 0:
+		sc.tokenFound(TokenTypeText, sc.scanText)
+		return i, data[:i], nil
+	case data[0] == '\\':
+		sc.tokenFound(TokenTypeEscape, func(data []byte, atEOF bool) (int, []byte, error) {
+			// The escaped <!-- does not start a comment.
+			return sc.scanTextFrom(data, atEOF, 1)
+		})
+		return 1, data[:1], nil
+	default:
+		return sc.goToState(sc.scanComment, data, atEOF)
+	}
+}
+
+func (sc *Scanner) scanHardBreak(data []byte, atEOF bool) (int, []byte, error) {
+	sc.tokenFound(TokenTypeHardBreak, sc.scanLine)
+	return 2, data[:2], nil
+}
+
+func (sc *Scanner) scanQuote(data []byte, atEOF bool) (int, []byte, error) {
+	sc.tokenFound(TokenTypeQuoteMod, sc.scanLine)
+	i, tok, err := sc.scanFunc(data, atEOF, notIsHSpace)
+	return i, tok, err
+}
+func (sc *Scanner) scanFmtMod(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 3 {
+		if atEOF {
+			// This can't be a format modifier. Read it as normal line.
+			return sc.goToState(sc.scanText, data, atEOF)
+		}
+		// Read more data
+		return 0, nil, nil
+	}
+	if string(data[0:3]) != "```" || sc.pos.Col != 1 {
+		// Not a format modifier. Read it as normal line. Format modifiers
+		// have to start at the beginning of a line.
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+	sc.preFmt = !sc.preFmt
+	sc.tokenFound(TokenTypePreFmtMod, sc.scanLine)
+	return 3, data[0:3], nil
+}
+
+func (sc *Scanner) scanIndent(data []byte, atEOF bool) (int, []byte, error) {
+	sc.tokenFound(TokenTypeIndent, sc.scanLine)
+	return sc.scanFunc(data, atEOF, notIsHSpace)
+}
+
+func (sc *Scanner) scanBulletPoint(data []byte, atEOF bool) (int, []byte, error) {
+	sc.tokenFound(TokenTypeBulletPoint, sc.scanLine)
+	i, tok, err := sc.scanFunc(data, atEOF, notIsHSpace)
+	return i, tok, err
+}
+
+func (sc *Scanner) scanLinkMod(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 2 {
+		if atEOF {
+			// Not enough input left. This can't be be a link modifier.
+			return sc.goToState(sc.scanText, data, atEOF)
+		}
+		return 0, nil, nil // read more data
+	}
+	if data[1] != '>' {
+		// Not a link modifier
+		return sc.goToState(sc.scanText, data, atEOF)
+	}
+	// Include any horizontal space following the link modifier.
+	i := bytes.IndexFunc(data[2:], notIsHSpace) + 2
+	if i == 1 {
+		if !atEOF {
+			return 0, nil, nil // read more data
+		}
+		i = len(data)
+	}
+	if i == len(data) || isVSpace(rune(data[i])) {
+		// The link does not have an URI.
+		sc.tokenFound(TokenTypeLinkMod, sc.scanLine)
+		return i, data[0:i], nil
+	}
+	sc.tokenFound(TokenTypeLinkMod, sc.scanLinkURI)
+	return i, data[0:i], nil
+}
+
+func (sc *Scanner) scanLinkURI(data []byte, atEOF bool) (int, []byte, error) {
+	return sc.scanChunked(data, atEOF, TokenTypeLinkURI, sc.scanLinkURI, sc.scanLinkText, isSpace)
+}
+
+func (sc *Scanner) scanLinkText(data []byte, atEOF bool) (int, []byte, error) {
+	if isVSpace(rune(data[0])) {
+		// The link does not have a text.
+		return sc.goToState(sc.scanLine, data, atEOF)
+	}
+	if i := sc.inlineComment(data, 0); i >= 0 {
+		return sc.scanInlineComment(data, atEOF, i)
+	}
+	return sc.scanChunked(data, atEOF, TokenTypeText, sc.scanLinkText, sc.scanLine, isVSpace)
+}
+
+func (sc *Scanner) scanHeadingMod(data []byte, atEOF bool) (int, []byte, error) {
+	// The heading modifier consists of all leading pound characters and any
+	// horizontal space following them.
+	i := bytes.IndexFunc(data, notIsRune('#'))
+	if i != -1 {
+		if j := bytes.IndexFunc(data[i:], notIsHSpace); j != -1 {
+			i += j
+		} else {
+			i = -1
+		}
+	}
+	if i == -1 {
+		if !atEOF {
+			return 0, nil, nil // read more data
+		}
+		i = len(data)
+	}
+	next := sc.scanLine
+	if i < len(data) && !isVSpace(rune(data[i])) {
+		// Whatever follows the heading modifier is the heading's text.
+		next = sc.scanText
+	}
+	sc.tokenFound(TokenTypeHeadingMod, next)
+	return i, data[0:i], nil
+}
+
+func (sc *Scanner) scanFunc(data []byte, atEOF bool, f func(r rune) bool) (int, []byte, error) {
+	if len(data) == 1 && atEOF {
+		// Return what we have got.
+		return 1, data[0:1], nil
+	}
+	// Find the index of the first rune that matches f, excluding data[0].
+	// Add 1 since we offset our data by 1.
+	i := bytes.IndexFunc(data[1:], f) + 1
+	if i == 0 {
+		if !atEOF {
+			return 0, nil, nil // Read more data
+		}
+		i = len(data)
+	}
+	return i, data[0:i], nil
+}
+
+// scanChunked scans a token of type typ which ends right before the first
+// rune matching f. Once the token was found the Scanner continues in the next
+// state.
+//
+// If the token would exceed the maximum token size, scanChunked splits it
+// into several consecutive tokens of type typ. This allows to scan
+// arbitrarily long lines of text without having to keep them in memory. The
+// Scanner continues with the state cont after each chunk. cont has to call
+// scanChunked with the same arguments eventually.
+func (sc *Scanner) scanChunked(
+	data []byte, atEOF bool, typ TokenType, cont, next bufio.SplitFunc, f func(r rune) bool,
+) (int, []byte, error) {
+	i, tok, err := sc.scanFunc(data, atEOF, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	if i > 0 {
+		sc.tokenFound(typ, next)
+		return i, tok, nil
+	}
+	if len(data) < sc.maxTokenSize {
+		return 0, nil, nil // Stay in the current state and read more data.
+	}
+	// Do not split the data in the middle of a multi-byte rune, unless the
+	// data is not valid UTF-8 anyways.
+	i = sc.maxTokenSize
+	j := i - 1
+	for j > 0 && j > i-utf8.UTFMax && !utf8.RuneStart(data[j]) {
+		j--
+	}
+	if j > 0 && !utf8.FullRune(data[j:i]) {
+		i = j
+	}
+	sc.tokenFound(typ, func(data []byte, atEOF bool) (int, []byte, error) {
+		if f(rune(data[0])) {
+			// The previous chunk was the last one of the token.
+			return sc.goToState(next, data, atEOF)
+		}
+		return cont(data, atEOF)
+	})
+	return i, data[0:i], nil
+}
+
+func (sc *Scanner) goToState(state bufio.SplitFunc, data []byte, atEOF bool) (int, []byte, error) {
+	sc.state = state
+	return sc.state(data, atEOF)
+}
+
+// tokenFound sets the type of token that was found during the current call
+// to Scan. nextState is the state the next call to Scan will start with.
+func (sc *Scanner) tokenFound(typ TokenType, nextState bufio.SplitFunc) {
+	sc.token.Type = typ
+	sc.state = nextState
+}
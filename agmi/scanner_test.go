@@ -0,0 +1,865 @@
+package agmi_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner_Scan(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []agmi.Token
+	}{
+		{
+			name:  "Scan modeline with close comment symbol",
+			input: "<!-- vim: set tw=72 ft=markdown: -->\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeModeline,
+					Text: "<!-- vim: set tw=72 ft=markdown: -->",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Scan modeline without close comment symbol",
+			input: "<!-- vim: set tw=72 ft=markdown:\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeModeline,
+					Text: "<!-- vim: set tw=72 ft=markdown:",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Scan heading",
+			input: "## A heading\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeHeadingMod,
+					Text: "## ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "A heading",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Scan heading without space",
+			input: "#A heading with # inside",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeHeadingMod,
+					Text: "#",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "A heading with # inside",
+				},
+			},
+		},
+		{
+			name:  "Scan two paragraphs",
+			input: "The first paragraph.\n\nThe second paragraph.",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeText,
+					Text: "The first paragraph.",
+				},
+				{
+					Type: agmi.TokenTypeParSep,
+					Text: "\n\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "The second paragraph.",
+				},
+			},
+		},
+		{
+			name:  "Scan single line of arbitrary text with trailing newline",
+			input: "This is a line of text.\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is a line of text.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Scan single line of arbitrary text without trailing newline",
+			input: "This is a line of text.",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is a line of text.",
+				},
+			},
+		},
+		{
+			name:  "Scan two consecutive lines of arbitrary text",
+			input: "This is a line of text.\nThis is another one.\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is a line of text.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is another one.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Single quote line",
+			input: "> This is a quote",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: "> ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is a quote",
+				},
+			},
+		},
+		{
+			name:  "Multiple quote lines",
+			input: "> First line of quote.\n> Second line of quote.",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: "> ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "First line of quote.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: "> ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Second line of quote.",
+				},
+			},
+		},
+		{
+			name:  "Multi paragraph quote",
+			input: "> First line of quote.\n>\n> Second line of quote.",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: "> ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "First line of quote.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: ">", // Note the missing space!
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeQuoteMod,
+					Text: "> ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Second line of quote.",
+				},
+			},
+		},
+		{
+			name:  "Pre-formatted text with ```",
+			input: "```\nThis is pre-formatted.\nThis as well.\n```",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypePreFmtMod,
+					Text: "```",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is pre-formatted.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This as well.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypePreFmtMod,
+					Text: "```",
+				},
+			},
+		},
+		{
+			name:  "Pre-formatted with space indent",
+			input: "    This is pre-formatted.\n    This as well.\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "    ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is pre-formatted.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "    ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This as well.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "Pre-formatted with tab indent",
+			input: "\tThis is pre-formatted.\n\tThis as well.\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "\t",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This is pre-formatted.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "\t",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "This as well.",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "List items",
+			input: "* First list item\n*Second list item",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeBulletPoint,
+					Text: "* ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "First list item",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeBulletPoint,
+					Text: "*", // Note the missing space.
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Second list item",
+				},
+			},
+		},
+		{
+			name:  "Multi-line list items",
+			input: "* First list item\n  With a second line\n*Second list item\n  With another line",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeBulletPoint,
+					Text: "* ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "First list item",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "  ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "With a second line",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeBulletPoint,
+					Text: "*", // Note the missing space.
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Second list item",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeIndent,
+					Text: "  ",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "With another line",
+				},
+			},
+		},
+		{
+			name:  "link to HTTP",
+			input: "=> gemini://example.com This is a link\n",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeLinkMod,
+					Text: "=> ",
+				},
+				{
+					Type: agmi.TokenTypeLinkURI,
+					Text: "gemini://example.com",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: " This is a link",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+			},
+		},
+		{
+			name:  "link without text",
+			input: "=> gemini://example.com\nSome text",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeLinkMod,
+					Text: "=> ",
+				},
+				{
+					Type: agmi.TokenTypeLinkURI,
+					Text: "gemini://example.com",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Some text",
+				},
+			},
+		},
+		{
+			name:  "link without URI",
+			input: "=> \nSome text",
+			expected: []agmi.Token{
+				{
+					Type: agmi.TokenTypeLinkMod,
+					Text: "=> ",
+				},
+				{
+					Type: agmi.TokenTypeLineBreak,
+					Text: "\n",
+				},
+				{
+					Type: agmi.TokenTypeText,
+					Text: "Some text",
+				},
+			},
+		},
+		{
+			name:  "hard break",
+			input: "Roses are red,\\\nviolets are blue.\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Roses are red,"},
+				{Type: agmi.TokenTypeHardBreak, Text: "\\\n"},
+				{Type: agmi.TokenTypeText, Text: "violets are blue."},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+			},
+		},
+		{
+			name:  "backslash on a line of its own",
+			input: "a\n\\\nb",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "a"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "\\"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "b"},
+			},
+		},
+		{
+			name:  "backslash before paragraph separator",
+			input: "a\\\n\nb",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "a\\"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+				{Type: agmi.TokenTypeText, Text: "b"},
+			},
+		},
+		{
+			name:  "backslash at end of document",
+			input: "a\\\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "a\\"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+			},
+		},
+		{
+			name:  "escaped bullet point",
+			input: "\\* not a bullet point",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "* not a bullet point"},
+			},
+		},
+		{
+			name:  "escaped markers",
+			input: "\\> a\n\\=> b\n\\# c\n\\```\n\\<!-- d\n\\\\e",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "> a"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "=> b"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "# c"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "```"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "<!-- d"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "\\e"},
+			},
+		},
+		{
+			name:  "escaped backslash at end of line",
+			input: "\\\\\nfoo\n\\\\\\\nbar",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "\\"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "foo"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "\\"},
+				{Type: agmi.TokenTypeHardBreak, Text: "\\\n"},
+				{Type: agmi.TokenTypeText, Text: "bar"},
+			},
+		},
+		{
+			name:  "backslash without marker",
+			input: "\\o/\n\\",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "\\o/"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "\\"},
+			},
+		},
+		{
+			name:  "modelines on first and last line",
+			input: "\n<!-- first -->\nText\n<!-- last -->\n\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeModeline, Text: "<!-- first -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeModeline, Text: "<!-- last -->"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+			},
+		},
+		{
+			name:  "comments within the document",
+			input: "Text\n<!-- not a modeline -->\nText\n\n    <!-- indented",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- not a modeline -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+				{Type: agmi.TokenTypeIndent, Text: "    "},
+				{Type: agmi.TokenTypeText, Text: "<!-- indented"},
+			},
+		},
+		{
+			name:  "comment spanning several lines",
+			input: "Text\n<!-- TODO:\n* item\n\n=> link -->\nText",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- TODO:\n* item\n\n=> link -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "Text"},
+			},
+		},
+		{
+			name:  "comment spanning several lines at the start of the document",
+			input: "<!-- draft notes\nsecret\n-->\n\n# Title\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeComment, Text: "<!-- draft notes\nsecret\n-->"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+				{Type: agmi.TokenTypeHeadingMod, Text: "# "},
+				{Type: agmi.TokenTypeText, Text: "Title"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+			},
+		},
+		{
+			name:  "text following a comment",
+			input: "Text\n<!----> * not an item\nText",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!---->"},
+				{Type: agmi.TokenTypeText, Text: " * not an item"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "Text"},
+			},
+		},
+		{
+			name:  "unclosed comment",
+			input: "Text\n<!-- open\n\nText\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- open\n\nText\n"},
+			},
+		},
+		{
+			name:  "no comments within pre-formatted text",
+			input: "Text\n```\n<!-- code\n```\n<!-- comment -->\nText",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypePreFmtMod, Text: "```"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "<!-- code"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypePreFmtMod, Text: "```"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- comment -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeText, Text: "Text"},
+			},
+		},
+		{
+			name:  "comments within lines",
+			input: "Text <!-- a --> more\n* \\<!-- b --> c\\<!-- d\n    code <!-- e -->\n=> gemini://x<!-- Text<!-- f -->",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeText, Text: "Text "},
+				{Type: agmi.TokenTypeComment, Text: "<!-- a -->"},
+				{Type: agmi.TokenTypeText, Text: " more"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeBulletPoint, Text: "* "},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "<!-- b --> c"},
+				{Type: agmi.TokenTypeEscape, Text: "\\"},
+				{Type: agmi.TokenTypeText, Text: "<!-- d"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeIndent, Text: "    "},
+				{Type: agmi.TokenTypeText, Text: "code <!-- e -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeLinkMod, Text: "=> "},
+				{Type: agmi.TokenTypeLinkURI, Text: "gemini://x<!--"},
+				{Type: agmi.TokenTypeText, Text: " Text"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- f -->"},
+			},
+		},
+		{
+			name:  "indented fence does not start pre-formatted text",
+			input: "    ```\n\n<!-- hidden -->\n\n> ```\n<!-- hidden -->\n",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeIndent, Text: "    "},
+				{Type: agmi.TokenTypeText, Text: "```"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+				{Type: agmi.TokenTypeComment, Text: "<!-- hidden -->"},
+				{Type: agmi.TokenTypeParSep, Text: "\n\n"},
+				{Type: agmi.TokenTypeQuoteMod, Text: "> "},
+				{Type: agmi.TokenTypeText, Text: "```"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+				{Type: agmi.TokenTypeModeline, Text: "<!-- hidden -->"},
+				{Type: agmi.TokenTypeLineBreak, Text: "\n"},
+			},
+		},
+		{
+			name:  "hard break in list item",
+			input: "* a\\\n  b",
+			expected: []agmi.Token{
+				{Type: agmi.TokenTypeBulletPoint, Text: "* "},
+				{Type: agmi.TokenTypeText, Text: "a"},
+				{Type: agmi.TokenTypeHardBreak, Text: "\\\n"},
+				{Type: agmi.TokenTypeIndent, Text: "  "},
+				{Type: agmi.TokenTypeText, Text: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sc := agmi.NewScanner(bytes.NewBufferString(tt.input))
+
+			nTokens := len(tt.expected)
+			for i, etok := range tt.expected {
+				assert.Truef(t, sc.Scan(), "Scan returned false for token %d/%d", i, nTokens)
+				if !assert.NoErrorf(t, sc.Err(), "Scan failed for token %d/%d", i, nTokens) {
+					return
+				}
+				tok := sc.Token()
+				assert.Equal(t, etok.Type, tok.Type)
+				assert.Equal(t, etok.Text, tok.Text)
+			}
+			assert.False(t, sc.Scan(), "Not all tokens consumed")
+		})
+	}
+}
+
+func TestScanner_Pos(t *testing.T) {
+	input := "# Title\n\n* Item\n  continued"
+	expected := []agmi.Pos{
+		{Offset: 0, Line: 1, Col: 1},  // "# "
+		{Offset: 2, Line: 1, Col: 3},  // "Title"
+		{Offset: 7, Line: 1, Col: 8},  // "\n\n"
+		{Offset: 9, Line: 3, Col: 1},  // "* "
+		{Offset: 11, Line: 3, Col: 3}, // "Item"
+		{Offset: 15, Line: 3, Col: 7}, // "\n"
+		{Offset: 16, Line: 4, Col: 1}, // "  "
+		{Offset: 18, Line: 4, Col: 3}, // "continued"
+	}
+
+	sc := agmi.NewScanner(bytes.NewBufferString(input))
+	for i, epos := range expected {
+		if !assert.Truef(t, sc.Scan(), "Scan returned false for token %d", i) {
+			return
+		}
+		assert.Equalf(t, epos, sc.Token().Pos, "token %d: %q", i, sc.Token().Text)
+	}
+	assert.False(t, sc.Scan(), "Not all tokens consumed")
+	assert.NoError(t, sc.Err())
+}
+
+func TestScanner_LongTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		tokenType agmi.TokenType
+	}{
+		{
+			name:      "long line of text",
+			input:     strings.Repeat("Lorem ipsum dolor sit amet. ", 10000),
+			tokenType: agmi.TokenTypeText,
+		},
+		{
+			name:      "long line of multi-byte runes",
+			input:     strings.Repeat("äöü€", 50000),
+			tokenType: agmi.TokenTypeText,
+		},
+		{
+			name:      "long modeline",
+			input:     "<!-- " + strings.Repeat("x", 200000),
+			tokenType: agmi.TokenTypeModeline,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var actual strings.Builder
+
+			sc := agmi.NewScanner(strings.NewReader(tt.input + "\n"))
+			for sc.Scan() {
+				tok := sc.Token()
+				if tok.Type == agmi.TokenTypeLineBreak {
+					break
+				}
+				assert.Equal(t, tt.tokenType, tok.Type)
+				assert.LessOrEqual(t, len(tok.Text), agmi.MaxTokenSize)
+				assert.True(t, utf8.ValidString(tok.Text))
+				assert.Equal(t, actual.Len(), tok.Pos.Offset)
+				actual.WriteString(tok.Text)
+			}
+			assert.NoError(t, sc.Err())
+			assert.Equal(t, tt.input, actual.String())
+			assert.False(t, sc.Scan(), "Not all tokens consumed")
+		})
+	}
+}
+
+func TestScanner_LongComment(t *testing.T) {
+	comment := "<!-- " + strings.Repeat("äöü€\n", 50000) + "-->"
+
+	var actual strings.Builder
+	sc := agmi.NewScanner(strings.NewReader("Text\n" + comment + "\nText"))
+	for sc.Scan() {
+		tok := sc.Token()
+		if tok.Type != agmi.TokenTypeComment {
+			continue
+		}
+		assert.LessOrEqual(t, len(tok.Text), agmi.MaxTokenSize)
+		assert.True(t, utf8.ValidString(tok.Text))
+		actual.WriteString(tok.Text)
+	}
+	assert.NoError(t, sc.Err())
+	assert.Equal(t, comment, actual.String())
+}
+
+func TestScanner_LongLinkURI(t *testing.T) {
+	uri := "data:text/plain;base64," + strings.Repeat("QUJD", 50000)
+	input := "Text\n\n=> " + uri + " Link text\n"
+
+	var (
+		actualURI string
+		tokens    []agmi.TokenType
+	)
+	sc := agmi.NewScanner(strings.NewReader(input))
+	sc.Buffer(nil, 1024)
+	for sc.Scan() {
+		tok := sc.Token()
+		if tok.Type == agmi.TokenTypeLinkURI {
+			actualURI += tok.Text
+		}
+		if len(tokens) == 0 || tokens[len(tokens)-1] != tok.Type {
+			tokens = append(tokens, tok.Type)
+		}
+	}
+	assert.NoError(t, sc.Err())
+	assert.Equal(t, uri, actualURI)
+	assert.Equal(t, []agmi.TokenType{
+		agmi.TokenTypeText,
+		agmi.TokenTypeParSep,
+		agmi.TokenTypeLinkMod,
+		agmi.TokenTypeLinkURI,
+		agmi.TokenTypeText,
+		agmi.TokenTypeLineBreak,
+	}, tokens)
+}
+
+func TestScanner_LongLineHardBreak(t *testing.T) {
+	line := strings.Repeat("x", 100)
+
+	sc := agmi.NewScanner(strings.NewReader(line + "\\\n" + line))
+	sc.Buffer(nil, 16)
+
+	var (
+		text   strings.Builder
+		breaks int
+	)
+	for sc.Scan() {
+		tok := sc.Token()
+		switch tok.Type {
+		case agmi.TokenTypeText:
+			text.WriteString(tok.Text)
+		case agmi.TokenTypeHardBreak:
+			breaks++
+		default:
+			t.Errorf("unexpected token: %s %q", tok.Type, tok.Text)
+		}
+	}
+	assert.NoError(t, sc.Err())
+	assert.Equal(t, 1, breaks)
+	assert.Equal(t, line+line, text.String())
+}
+
+func TestScanner_TokenTooLong(t *testing.T) {
+	input := "Some text\n\n" + strings.Repeat(" ", 100) + "pre-formatted"
+
+	sc := agmi.NewScanner(strings.NewReader(input))
+	sc.Buffer(nil, 16)
+	for sc.Scan() {
+	}
+	err := sc.Err()
+	assert.True(t, errors.Is(err, bufio.ErrTooLong))
+	assert.EqualError(t, err, "3:1: token exceeds maximum size of 16 bytes")
+
+	var serr *agmi.SyntaxError
+	if assert.True(t, errors.As(err, &serr)) {
+		assert.Equal(t, agmi.SyntaxErrorTokenTooLong, serr.Kind)
+		assert.Equal(t, agmi.Pos{Offset: 11, Line: 3, Col: 1}, serr.Pos)
+	}
+}
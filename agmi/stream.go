@@ -0,0 +1,97 @@
+package agmi
+
+// TokenStream is a stream of Tokens.
+//
+// Scanner is the most basic TokenStream. Filters create new TokenStreams
+// based on existing ones.
+type TokenStream interface {
+	// Scan advances the stream to the next Token. It returns false once the
+	// stream is exhausted or an error occurred.
+	Scan() bool
+
+	// Token returns the Token the previous call to Scan advanced to.
+	Token() Token
+
+	// Err returns the error that stopped the stream, if any.
+	Err() error
+}
+
+// Filter creates a new TokenStream from ts.
+//
+// Filters allow to remove, add, or modify Tokens before they are processed
+// by a Converter. This allows to implement transformations which are
+// independent of the output format once and use them for all formats.
+type Filter func(ts TokenStream) TokenStream
+
+// FilterFunc is called for each Token of a TokenStream by the TokenStream
+// returned from FilterTokens. It may call emit any number of times to pass
+// Tokens on.
+//
+// Once the underlying stream is exhausted FilterFunc is called a last time
+// with the zero Token. This allows to emit any Tokens held back. Returning an
+// error stops the stream.
+type FilterFunc func(tok Token, emit func(Token)) error
+
+// FilterTokens returns a TokenStream which passes each Token of ts to f and
+// contains the Tokens emitted by f.
+func FilterTokens(ts TokenStream, f FilterFunc) TokenStream {
+	return &filterStream{ts: ts, f: f}
+}
+
+type filterStream struct {
+	ts   TokenStream
+	f    FilterFunc
+	buf  []Token // Tokens emitted by f but not yet returned.
+	tok  Token
+	err  error
+	done bool
+}
+
+func (fs *filterStream) Scan() bool {
+	for len(fs.buf) == 0 {
+		if fs.done {
+			fs.tok = Token{}
+			return false
+		}
+		tok := Token{}
+		if fs.ts.Scan() {
+			tok = fs.ts.Token()
+		} else {
+			fs.done = true
+			if fs.ts.Err() != nil {
+				continue
+			}
+		}
+		if err := fs.f(tok, fs.emit); err != nil {
+			fs.err = err
+			fs.done = true
+			fs.buf = nil
+		}
+	}
+	fs.tok, fs.buf = fs.buf[0], fs.buf[1:]
+	return true
+}
+
+func (fs *filterStream) emit(tok Token) {
+	fs.buf = append(fs.buf, tok)
+}
+
+func (fs *filterStream) Token() Token {
+	return fs.tok
+}
+
+func (fs *filterStream) Err() error {
+	if fs.err != nil {
+		return fs.err
+	}
+	return fs.ts.Err()
+}
+
+// ApplyFilters applies filters in order to ts and returns the resulting
+// TokenStream.
+func ApplyFilters(ts TokenStream, filters ...Filter) TokenStream {
+	for _, f := range filters {
+		ts = f(ts)
+	}
+	return ts
+}
@@ -0,0 +1,180 @@
+package agmi
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tocMarker matches the comment marking the position of the table of
+// contents.
+var tocMarker = regexp.MustCompile(`^<!--\s*toc\s*-->$`)
+
+// tocIndent indents an entry of the table of contents by one level
+// relative to the entry of its parent heading. It consists of no-break
+// spaces, since Gemini clients usually collapse regular spaces.
+const tocIndent = "\u00a0\u00a0"
+
+// TableOfContents replaces the comment <!-- toc --> with a list of all
+// headings following it. Lines starting with # which continue a paragraph
+// are not headings and thus not listed.
+//
+// Each heading becomes a list item. Items of deeper headings are indented
+// with no-break spaces relative to the item of the least deep heading. Only
+// the first comment <!-- toc --> of a document is replaced. The list is
+// separated from the surrounding text by blank lines. The comment is
+// retained if no headings follow it.
+//
+// TableOfContents has to hold back all Tokens following the comment until
+// the end of the document is reached.
+func TableOfContents() Filter {
+	return func(ts TokenStream) TokenStream {
+		var (
+			lt       = newLineTracker()
+			lineEnd  Token   // Line end held back until the next line is known.
+			marker   Token   // Comment marking the table of contents.
+			before   Token   // Line end preceding the marker.
+			held     []Token // Tokens following the marker.
+			headings []tocEntry
+			heading  *tocEntry // Heading currently being read.
+			parStart bool      // The next line starts a paragraph.
+		)
+
+		return FilterTokens(ts, func(tok Token, emit func(Token)) error {
+			defer lt.update(tok)
+
+			switch {
+			case tok.IsZero():
+				if marker.IsZero() {
+					if !lineEnd.IsZero() {
+						emit(lineEnd)
+					}
+					return nil
+				}
+				for _, t := range tocTokens(marker, before, headings, held) {
+					emit(t)
+				}
+				return nil
+			case marker.IsZero() && isTOCMarker(tok) && lt.isBlockStart():
+				// The table of contents ends the paragraph, if any, so
+				// the next line starts a new one.
+				marker, before, parStart = tok, lineEnd, true
+				return nil
+			case marker.IsZero() && isLineEnd(tok):
+				if !lineEnd.IsZero() {
+					emit(lineEnd)
+				}
+				lineEnd = tok
+				return nil
+			case marker.IsZero():
+				if !lineEnd.IsZero() {
+					emit(lineEnd)
+					lineEnd = Token{}
+				}
+				emit(tok)
+				return nil
+			}
+
+			held = append(held, tok)
+			switch {
+			case tok.Type == TokenTypeHeadingMod && lt.isBlockStart() && parStart:
+				headings = append(headings, tocEntry{level: strings.Count(tok.Text, "#")})
+				heading = &headings[len(headings)-1]
+			case tok.Type == TokenTypeParSep || tok.Type == TokenTypeModeline:
+				heading = nil
+			case isLineEnd(tok):
+				// Lines continuing the paragraph of a heading are part of
+				// the heading.
+				if heading != nil {
+					heading.text += " "
+				}
+				return nil
+			case heading != nil && tok.Type != TokenTypeEscape && tok.Type != TokenTypeComment:
+				heading.text += tok.Text
+			}
+			// Comments are removed later on and do not start a paragraph.
+			parStart = tok.Type == TokenTypeParSep || parStart && (isHSpaceText(tok) || tok.Type == TokenTypeComment)
+			return nil
+		})
+	}
+}
+
+// isTOCMarker returns true if tok is the comment marking the position of the
+// table of contents. On the first line of a document the comment is a
+// modeline.
+func isTOCMarker(tok Token) bool {
+	return (tok.Type == TokenTypeComment || tok.Type == TokenTypeModeline) && tocMarker.MatchString(tok.Text)
+}
+
+// isHSpaceText returns true if tok is text consisting of horizontal white
+// space only.
+func isHSpaceText(tok Token) bool {
+	return tok.Type == TokenTypeText && strings.TrimLeft(tok.Text, " \t") == ""
+}
+
+// tocEntry is a heading listed in the table of contents.
+type tocEntry struct {
+	level int
+	text  string
+}
+
+// tocTokens returns the Tokens replacing marker, the line end before it,
+// and the Tokens after it. These are the Tokens of a list of headings,
+// separated from the surrounding Tokens by paragraph separators. If
+// headings is empty, tocTokens returns the original Tokens.
+func tocTokens(marker, before Token, headings []tocEntry, after []Token) []Token {
+	var toks []Token
+	if len(headings) == 0 {
+		if !before.IsZero() {
+			toks = append(toks, before)
+		}
+		toks = append(toks, marker)
+		return append(toks, after...)
+	}
+	minLevel := headings[0].level
+	for _, h := range headings {
+		if h.level < minLevel {
+			minLevel = h.level
+		}
+	}
+
+	if !before.IsZero() {
+		toks = append(toks, parSep(before))
+	}
+	for i, h := range headings {
+		if i > 0 {
+			toks = append(toks, Token{Type: TokenTypeLineBreak, Pos: marker.Pos, Text: "\n"})
+		}
+		text := strings.Repeat(tocIndent, h.level-minLevel) + strings.Join(strings.FieldsFunc(h.text, isHSpace), " ")
+		toks = append(toks,
+			Token{Type: TokenTypeBulletPoint, Pos: marker.Pos, Text: "* "},
+			Token{Type: TokenTypeText, Pos: marker.Pos, Text: text},
+		)
+	}
+	// Drop white space following the marker on the same line.
+	for len(after) > 0 && after[0].Type == TokenTypeText && strings.TrimLeft(after[0].Text, " \t") == "" {
+		after = after[1:]
+	}
+	switch {
+	case len(after) == 0:
+	case isLineEnd(after[0]):
+		toks = append(toks, parSep(after[0]))
+		after = after[1:]
+	default:
+		// Text following the marker on the same line starts a new
+		// paragraph.
+		toks = append(toks, parSep(marker))
+		if after[0].Type == TokenTypeText {
+			after[0].Text = strings.TrimLeft(after[0].Text, " \t")
+		}
+	}
+	return append(toks, after...)
+}
+
+// parSep returns tok if it is a paragraph separator. Otherwise it returns a
+// paragraph separator at the position of tok.
+func parSep(tok Token) Token {
+	if tok.Type == TokenTypeParSep {
+		return tok
+	}
+	return Token{Type: TokenTypeParSep, Pos: tok.Pos, Text: "\n\n"}
+}
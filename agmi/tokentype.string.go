@@ -16,14 +16,18 @@ func _() {
 	_ = x[TokenTypePreFmtMod-5]
 	_ = x[TokenTypeLinkMod-6]
 	_ = x[TokenTypeLinkURI-7]
-	_ = x[TokenTypeBulletPoint-8]
-	_ = x[TokenTypeIndent-9]
-	_ = x[TokenTypeText-10]
+	_ = x[TokenTypeHeadingMod-8]
+	_ = x[TokenTypeBulletPoint-9]
+	_ = x[TokenTypeIndent-10]
+	_ = x[TokenTypeText-11]
+	_ = x[TokenTypeHardBreak-12]
+	_ = x[TokenTypeEscape-13]
+	_ = x[TokenTypeComment-14]
 }
 
-const _TokenType_name = "tokenTypeUnknownModelineLineBreakParSepQuoteModPreFmtModLinkModLinkURIBulletPointIndentText"
+const _TokenType_name = "tokenTypeUnknownModelineLineBreakParSepQuoteModPreFmtModLinkModLinkURIHeadingModBulletPointIndentTextHardBreakEscapeComment"
 
-var _TokenType_index = [...]uint8{0, 16, 24, 33, 39, 47, 56, 63, 70, 81, 87, 91}
+var _TokenType_index = [...]uint8{0, 16, 24, 33, 39, 47, 56, 63, 70, 80, 91, 97, 101, 110, 116, 123}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
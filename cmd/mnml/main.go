@@ -8,10 +8,10 @@ import (
 )
 
 func main() {
-	mnml := mnml.New()
+	cmd := mnml.New()
 
-	if err := mnml.Execute(); err != nil {
-		fmt.Printf("Error: %v", err)
-		os.Exit(1)
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(mnml.ExitCode(err))
 	}
 }
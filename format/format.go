@@ -0,0 +1,108 @@
+// Package format provides a registry of the output formats mnml is able to
+// create from Almost Gemtext.
+//
+// Packages implementing an output format register it by calling Register
+// from an init function. Programs using mnml as a library make a format
+// available by importing its package, possibly only for its side effects:
+//
+//	import _ "github.com/fhofherr/mnml/gemtext"
+package format
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Converter converts the Almost Gemtext document read from in to some other
+// format and writes the result to out.
+type Converter func(in io.Reader, out io.Writer) error
+
+// Format describes an output format.
+type Format struct {
+	Name      string    // Unique name of the format, e.g. gemtext.
+	Extension string    // File extension including the leading dot, e.g. .gmi.
+	MIMEType  string    // MIME type of documents in this format, e.g. text/gemini.
+	Convert   Converter // Converts Almost Gemtext to this format.
+}
+
+// ErrDuplicate is returned by Register if a format with the same name has
+// already been registered.
+var ErrDuplicate = errors.New("format already registered")
+
+var (
+	mu      sync.RWMutex
+	formats = make(map[string]Format)
+)
+
+// Register makes the format f available under its name.
+//
+// Register returns an error if f has no name or converter, if its
+// extension does not start with a dot, or if a format of the same name has
+// already been registered.
+func Register(f Format) error {
+	const op = "format/Register"
+
+	if f.Name == "" {
+		return fmt.Errorf("%s: missing name", op)
+	}
+	if f.Convert == nil {
+		return fmt.Errorf("%s: %s: missing converter", op, f.Name)
+	}
+	if !strings.HasPrefix(f.Extension, ".") {
+		return fmt.Errorf("%s: %s: invalid extension: %q", op, f.Name, f.Extension)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := formats[f.Name]; ok {
+		return fmt.Errorf("%s: %s: %w", op, f.Name, ErrDuplicate)
+	}
+	formats[f.Name] = f
+	return nil
+}
+
+// MustRegister calls Register and panics if it returns an error.
+func MustRegister(f Format) {
+	if err := Register(f); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the format registered under name.
+func Lookup(name string) (Format, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	f, ok := formats[name]
+	return f, ok
+}
+
+// All returns all registered formats sorted by their names.
+func All() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	all := make([]Format, 0, len(formats))
+	for _, f := range formats {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Names returns the names of all registered formats in sorted order.
+func Names() []string {
+	all := All()
+	names := make([]string, len(all))
+	for i, f := range all {
+		names[i] = f.Name
+	}
+	return names
+}
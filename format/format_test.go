@@ -0,0 +1,81 @@
+package format_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/fhofherr/mnml/format"
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/fhofherr/mnml/gemtext"
+)
+
+func TestRegister(t *testing.T) {
+	copyConverter := func(in io.Reader, out io.Writer) error {
+		_, err := io.Copy(out, in)
+		return err
+	}
+	tests := []struct {
+		name string
+		f    format.Format
+		err  error
+	}{
+		{
+			name: "register new format",
+			f:    format.Format{Name: "test-plain", Extension: ".txt", MIMEType: "text/plain", Convert: copyConverter},
+		},
+		{
+			name: "register duplicate format",
+			f:    format.Format{Name: "gemtext", Extension: ".gmi", Convert: copyConverter},
+			err:  format.ErrDuplicate,
+		},
+		{
+			name: "missing name",
+			f:    format.Format{Extension: ".txt", Convert: copyConverter},
+			err:  errors.New("format/Register: missing name"),
+		},
+		{
+			name: "missing converter",
+			f:    format.Format{Name: "test-noconv", Extension: ".txt"},
+			err:  errors.New("format/Register: test-noconv: missing converter"),
+		},
+		{
+			name: "invalid extension",
+			f:    format.Format{Name: "test-ext", Extension: "txt", Convert: copyConverter},
+			err:  errors.New(`format/Register: test-ext: invalid extension: "txt"`),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := format.Register(tt.f)
+			if tt.err != nil {
+				if errors.Is(tt.err, format.ErrDuplicate) {
+					assert.True(t, errors.Is(err, format.ErrDuplicate))
+				} else {
+					assert.EqualError(t, err, tt.err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			f, ok := format.Lookup(tt.f.Name)
+			assert.True(t, ok)
+			assert.Equal(t, tt.f.Extension, f.Extension)
+			assert.Contains(t, format.Names(), tt.f.Name)
+		})
+	}
+}
+
+func TestLookup_Gemtext(t *testing.T) {
+	f, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, ".gmi", f.Extension)
+	assert.Equal(t, "text/gemini", f.MIMEType)
+	assert.NotNil(t, f.Convert)
+}
@@ -1,36 +1,115 @@
 package gemtext
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
 	"unicode/utf8"
 
-	"github.com/fhofherr/mnml/internal/agmi"
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/internal/check"
 )
 
 // FromAlmostGemtext creates a Gemtext document of the Almost Gemtext
 // document read from in and writes it to out.
 func FromAlmostGemtext(in io.Reader, out io.Writer) error {
-	const op = "gemtext/FromAlmostGemtext"
+	return fromAlmostGemtext("gemtext/FromAlmostGemtext", in, out)
+}
+
+// FromAlmostGemtextWithOptions creates a Gemtext document of the Almost
+// Gemtext document read from in and writes it to out.
+//
+// Without any options FromAlmostGemtextWithOptions behaves exactly like
+// FromAlmostGemtext. Passing options allows to change the conversion.
+func FromAlmostGemtextWithOptions(in io.Reader, out io.Writer, opts ...Option) error {
+	return fromAlmostGemtext("gemtext/FromAlmostGemtextWithOptions", in, out, opts...)
+}
+
+// fromAlmostGemtext implements FromAlmostGemtext and
+// FromAlmostGemtextWithOptions. It prefixes all errors with op.
+func fromAlmostGemtext(op string, in io.Reader, out io.Writer, opts ...Option) error {
+	g := converter{
+		maxHeadingLevel: -1,
+	}
+	for _, opt := range opts {
+		opt(&g)
+	}
+
+	if g.strict && !g.gemtextCompatible {
+		var buf bytes.Buffer
+
+		diags, err := check.Document("", io.TeeReader(in, &buf))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		for _, d := range diags {
+			if d.Severity == check.SeverityError {
+				return fmt.Errorf("%s: %w", op, &agmi.SyntaxError{
+					Pos:  agmi.Pos{Offset: d.Offset, Line: d.Line, Col: d.Col},
+					Kind: agmi.SyntaxErrorKind(d.Rule),
+					Msg:  d.Message,
+				})
+			}
+		}
+		in = &buf
+	}
+
+	var filters []agmi.Filter
+	if !g.gemtextCompatible {
+		// The comment marking the table of contents is a modeline on the
+		// first line of a document.
+		filters = append(filters, agmi.TableOfContents())
+	}
+	if !g.keepModelines && !g.gemtextCompatible {
+		filters = append(filters, agmi.StripModelines())
+	}
+	if !g.gemtextCompatible {
+		filters = append(filters, agmi.StripComments(), agmi.ReferenceLinks())
+	}
+	if g.rewriteLink != nil {
+		filters = append(filters, agmi.RewriteLinks(g.rewriteLink))
+	}
 
-	c := agmi.NewConverter(in, out, fmtAGMIToken)
-	if err := c.Convert(); err != nil {
-		return fmt.Errorf("%s: %v", op, err)
+	tw := &trailingNewlineWriter{w: out, policy: g.trailingNewlines}
+	if g.gemtextCompatible {
+		if err := copyTokens(tw, agmi.ApplyFilters(agmi.NewScanner(in), filters...)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	} else {
+		c := agmi.NewConverter(in, tw, g.fmtAGMIToken, filters...)
+		if err := c.Convert(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func fmtAGMIToken(c *agmi.Converter, cur, next agmi.Token) {
+// converter holds the state functions converting Almost Gemtext to Gemtext,
+// as well as the options influencing them.
+type converter struct {
+	keepModelines     bool
+	normalizeParSep   bool
+	maxHeadingLevel   int
+	trailingNewlines  TrailingNewlines
+	rewriteLink       func(string) string
+	strict            bool
+	gemtextCompatible bool
+}
+
+func (g *converter) fmtAGMIToken(c *agmi.Converter, cur, next agmi.Token) {
 	switch cur.Type {
-	case agmi.TokenTypeModeline:
-		c.State = skipEmptyLines
+	case agmi.TokenTypeHeadingMod:
+		g.fmtHeadingMod(c, cur)
 	case agmi.TokenTypeQuoteMod:
 		c.Write("> ")
-		c.State = fmtQuoteLines
+		c.State = g.fmtQuoteLines
 	case agmi.TokenTypePreFmtMod:
 		c.Write("```")
-		c.State = fmtPreFmt
+		c.State = g.fmtPreFmt
 	case agmi.TokenTypeIndent:
 		if isSpaceIndent(cur.Text) && len(cur.Text) != 4 || (isTabIndent(cur.Text) && len(cur.Text) != 1) {
 			// Cannot be pre-formatted text since it would need to be indented
@@ -38,31 +117,89 @@ func fmtAGMIToken(c *agmi.Converter, cur, next agmi.Token) {
 			c.Write(cur.Text)
 			return
 		}
+		if next.IsZero() || next.IsBlank() {
+			// A blank line does not start pre-formatted text.
+			c.Write(cur.Text)
+			return
+		}
 		c.Write("```\n")
-		c.State = fmtPreFmtByIndent
+		c.State = g.fmtPreFmtByIndent
 	case agmi.TokenTypeBulletPoint:
 		c.Write("* ")
-		c.State = fmtListItem
+		c.State = g.fmtListItem
 	case agmi.TokenTypeLinkMod:
 		c.Write("=> ")
-		c.State = fmtLink
+		c.State = g.fmtLink
+	case agmi.TokenTypeModeline:
+		c.Write(cur.Text)
+		c.State = g.fmtModeline
 	case agmi.TokenTypeLineBreak:
 		joinLines(c, next)
+	case agmi.TokenTypeHardBreak:
+		c.Write("\n")
+	case agmi.TokenTypeParSep:
+		g.fmtParSep(c, cur)
+	case agmi.TokenTypeEscape:
+		// Drop the backslash. The escaped line is plain text.
 	default:
 		c.Write(cur.Text)
 	}
 }
 
-func fmtLink(c *agmi.Converter, cur, next agmi.Token) {
+func (g *converter) fmtHeadingMod(c *agmi.Converter, cur agmi.Token) {
+	level := strings.IndexFunc(cur.Text, func(r rune) bool { return r != '#' })
+	if level == -1 {
+		level = len(cur.Text)
+	}
+	c.State = g.fmtHeading
+	if g.maxHeadingLevel < 0 || level <= g.maxHeadingLevel {
+		c.Write(cur.Text)
+		return
+	}
+	c.Write(strings.Repeat("#", g.maxHeadingLevel) + cur.Text[level:])
+}
+
+// fmtHeading writes the text of a heading. Gemtext headings are single
+// lines, so all lines of the heading are joined, even those ending in a
+// hard line break.
+func (g *converter) fmtHeading(c *agmi.Converter, cur, next agmi.Token) {
+	switch cur.Type {
+	case agmi.TokenTypeHardBreak:
+		joinLines(c, next)
+	case agmi.TokenTypeParSep:
+		g.fmtParSep(c, cur)
+		c.State = g.fmtAGMIToken
+	default:
+		g.fmtAGMIToken(c, cur, next)
+	}
+}
+
+func (g *converter) fmtLink(c *agmi.Converter, cur, next agmi.Token) {
+	if cur.Type == agmi.TokenTypeLineBreak || cur.Type == agmi.TokenTypeParSep || cur.Type == agmi.TokenTypeHardBreak {
+		// The link ended. Return to fmtAGMIToken.
+		g.fmtParSep(c, cur)
+		c.State = g.fmtAGMIToken
+		return
+	}
+	if cur.Type == agmi.TokenTypeEscape {
+		// Drop the backslash.
+		return
+	}
 	c.Write(cur.Text)
+}
+
+// fmtModeline writes the line end following a modeline verbatim. The
+// modeline is a line of its own.
+func (g *converter) fmtModeline(c *agmi.Converter, cur, next agmi.Token) {
+	c.State = g.fmtAGMIToken
 	if cur.Type == agmi.TokenTypeLineBreak || cur.Type == agmi.TokenTypeParSep {
-		// The link ended. Return to fmtAGMIToken.
-		c.State = fmtAGMIToken
+		g.fmtParSep(c, cur)
 		return
 	}
+	g.fmtAGMIToken(c, cur, next)
 }
 
-func fmtListItem(c *agmi.Converter, cur, next agmi.Token) {
+func (g *converter) fmtListItem(c *agmi.Converter, cur, next agmi.Token) {
 	switch cur.Type {
 	case agmi.TokenTypeIndent:
 		if len(cur.Text) > 2 {
@@ -71,24 +208,29 @@ func fmtListItem(c *agmi.Converter, cur, next agmi.Token) {
 	case agmi.TokenTypeParSep:
 		// End of list
 		c.Write("\n\n")
-		c.State = fmtAGMIToken
+		c.State = g.fmtAGMIToken
+	case agmi.TokenTypeBulletPoint:
+		// Another list item directly follows the previous one.
+		c.Write("* ")
 	case agmi.TokenTypeLineBreak:
 		if next.Type == agmi.TokenTypeBulletPoint {
-			// Another list item is directly following the current one. Treat
-			// it as end of list. The fmtAGMIToken function will know how to handle
-			// it and delegate back to here.
-			c.State = fmtAGMIToken
 			c.Write("\n")
 			return
 		}
 		joinLines(c, next)
+	case agmi.TokenTypeHardBreak:
+		// The remainder of the list item starts on a line of its own.
+		c.Write("\n")
+	case agmi.TokenTypeEscape:
+		// Drop the backslash.
 	default:
 		c.Write(cur.Text)
 	}
 }
 
-func fmtPreFmtByIndent(c *agmi.Converter, cur, next agmi.Token) {
-	if cur.Type == agmi.TokenTypeIndent {
+func (g *converter) fmtPreFmtByIndent(c *agmi.Converter, cur, next agmi.Token) {
+	switch cur.Type {
+	case agmi.TokenTypeIndent:
 		// Skip leading indent if it is only four spaces or a single tab.
 		// Otherwise reduce it by four spaces or a single tab and write it
 		// to the output.
@@ -98,24 +240,70 @@ func fmtPreFmtByIndent(c *agmi.Converter, cur, next agmi.Token) {
 		if isTabIndent(cur.Text) && utf8.RuneCountInString(cur.Text) > 1 {
 			c.Write(strings.TrimPrefix(cur.Text, "\t"))
 		}
+	case agmi.TokenTypeLineBreak, agmi.TokenTypeParSep:
+		if isPreFmtIndent(nextLine(c)) {
+			c.Write(cur.Text)
+			return
+		}
+		// The first non-blank line which is not indented ends the block.
+		c.State = g.fmtAGMIToken
+		c.Write("\n```")
+		g.fmtParSep(c, cur)
 		return
+	default:
+		c.Write(cur.Text)
 	}
-	if next.IsZero() || (cur.Type == agmi.TokenTypeParSep && next.Type != agmi.TokenTypeIndent) {
-		// We reached the end of the pre-formatted block.
-		c.State = fmtAGMIToken
+	if next.IsZero() {
+		// The input ends within the last line of the pre-formatted block.
+		c.State = g.fmtAGMIToken
 		c.Write("\n```")
 	}
-	c.Write(cur.Text)
 }
 
-func fmtPreFmt(c *agmi.Converter, cur, _ agmi.Token) {
+// nextLine returns the first Token of the next non-blank line following the
+// current Token of c. The Token is an indent if the line is indented. It is
+// the zero Token if there is no further non-blank line.
+func nextLine(c *agmi.Converter) agmi.Token {
+	for i := 1; ; i++ {
+		tok := c.Peek(i)
+		switch {
+		case tok.IsZero():
+			return tok
+		case tok.IsBlank():
+			continue
+		case c.Peek(i-1).Type == agmi.TokenTypeIndent:
+			return c.Peek(i - 1)
+		default:
+			return tok
+		}
+	}
+}
+
+// isPreFmtIndent returns true if tok indents a line of pre-formatted text.
+func isPreFmtIndent(tok agmi.Token) bool {
+	return tok.Type == agmi.TokenTypeIndent && (isTabIndent(tok.Text) || strings.HasPrefix(tok.Text, "    "))
+}
+
+// copyTokens writes every Token of ts to w as is. The Tokens of a Gemtext
+// document add up to exactly the same document. Unlike an agmi.Converter,
+// copyTokens copies documents consisting of blank lines only as well.
+func copyTokens(w io.Writer, ts agmi.TokenStream) error {
+	for ts.Scan() {
+		if _, err := io.WriteString(w, ts.Token().Text); err != nil {
+			return err
+		}
+	}
+	return ts.Err()
+}
+
+func (g *converter) fmtPreFmt(c *agmi.Converter, cur, _ agmi.Token) {
 	c.Write(cur.Text)
 	if cur.Type == agmi.TokenTypePreFmtMod {
-		c.State = fmtAGMIToken
+		c.State = g.fmtAGMIToken
 	}
 }
 
-func fmtQuoteLines(c *agmi.Converter, cur, next agmi.Token) {
+func (g *converter) fmtQuoteLines(c *agmi.Converter, cur, next agmi.Token) {
 	switch cur.Type {
 	case agmi.TokenTypeQuoteMod:
 		// The initial > was already written by fmtAGMIToken. Skip any
@@ -123,24 +311,34 @@ func fmtQuoteLines(c *agmi.Converter, cur, next agmi.Token) {
 		return
 	case agmi.TokenTypeLineBreak:
 		joinLines(c, next)
+	case agmi.TokenTypeHardBreak:
+		c.Write("\n> ")
+	case agmi.TokenTypeEscape:
+		// Drop the backslash.
 	case agmi.TokenTypeParSep:
 		// We reached the end ouf our multi line quote.
-		c.Write(cur.Text)
-		c.State = fmtAGMIToken
+		g.fmtParSep(c, cur)
+		c.State = g.fmtAGMIToken
 	default:
 		// Anything else is part of the quote's text.
 		c.Write(cur.Text)
 	}
 }
 
-func skipEmptyLines(c *agmi.Converter, cur, next agmi.Token) {
-	if next.Type != agmi.TokenTypeLineBreak && next.Type != agmi.TokenTypeParSep {
-		c.State = fmtAGMIToken // Set next state.
+// fmtParSep writes the paragraph separator cur. It also accepts tokens of
+// type TokenTypeLineBreak and writes them verbatim.
+func (g *converter) fmtParSep(c *agmi.Converter, cur agmi.Token) {
+	if g.normalizeParSep && cur.Type == agmi.TokenTypeParSep {
+		c.Write("\n\n")
+		return
 	}
+	c.Write(cur.Text)
 }
 
+// joinLines writes the line break preceding next. It joins the lines by a
+// space unless the input ends or next is a modeline.
 func joinLines(c *agmi.Converter, next agmi.Token) {
-	if next.IsZero() {
+	if next.IsZero() || next.Type == agmi.TokenTypeModeline {
 		c.Write("\n")
 		return
 	}
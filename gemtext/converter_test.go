@@ -1,11 +1,18 @@
 package gemtext_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/fhofherr/mnml/agmi"
 	"github.com/fhofherr/mnml/gemtext"
 	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFromAlmostGemtext(t *testing.T) {
@@ -23,3 +30,309 @@ func TestFromAlmostGemtext(t *testing.T) {
 		t.Run(tt.Name, tt.Run)
 	}
 }
+
+func TestFromAlmostGemtext_LongLines(t *testing.T) {
+	par := strings.Repeat("Lorem ipsum dolor sit amet. ", 10000)
+	input := par + "\n" + par + "\n\n```\n" + par + "\n```\n"
+	expected := par + " " + par + "\n\n```\n" + par + "\n```\n"
+
+	var out strings.Builder
+	err := gemtext.FromAlmostGemtext(strings.NewReader(input), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, out.String())
+}
+
+func TestFromAlmostGemtextWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []gemtext.Option
+		expected string
+		err      string
+	}{
+		{
+			name:     "no options",
+			input:    "<!-- vim: set tw=72: -->\n\n# Title\n\n\n\nSome\ntext\n\n",
+			expected: "# Title\n\n\n\nSome text\n\n",
+		},
+		{
+			name:     "pre-formatted text by indent at end of input",
+			input:    "Some text\n\n    code\n    more code",
+			expected: "Some text\n\n```\ncode\nmore code\n```",
+		},
+		{
+			name:     "pre-formatted text by indent ends with the first line not indented",
+			input:    "    code\n  \n    more code\nSome text\n",
+			expected: "```\ncode\n\nmore code\n```\nSome text\n",
+		},
+		{
+			name:     "pre-formatted text by indent followed by a blank indented line",
+			input:    "    code\n    ",
+			expected: "```\ncode\n```\n    ",
+		},
+		{
+			name:     "hard line break within a heading",
+			input:    "# Title\\\ncontinued\n\nText\\\nmore",
+			expected: "# Title continued\n\nText\nmore",
+		},
+		{
+			name:     "table of contents on the first line",
+			input:    "<!-- toc -->\n\n# A\n\n## B\n<!-- vim: set tw=72: -->\n",
+			expected: "* A\n* \u00a0\u00a0B\n\n# A\n\n## B\n",
+		},
+		{
+			name:     "comment at the start of the text of a link",
+			input:    "=> gemini://x <!-- c --> text\n=> gemini://y <!-- c -->\n",
+			expected: "=> gemini://x text\n=> gemini://y\n",
+		},
+		{
+			name:     "list items following each other",
+			input:    "* First\n* Second\n  item\n\nText",
+			expected: "* First\n* Second item\n\nText",
+		},
+		{
+			name:     "gemtext compatible",
+			input:    "<!-- not a modeline -->\nSome\ntext\n    => a.agmi\n=> b.agmi\n",
+			opts:     []gemtext.Option{gemtext.GemtextCompatible(), gemtext.RewriteLinks(strings.ToUpper), gemtext.Strict()},
+			expected: "<!-- not a modeline -->\nSome\ntext\n    => a.agmi\n=> B.AGMI\n",
+		},
+		{
+			name:     "keep modelines",
+			input:    "<!-- vim: set tw=72: -->\n\n# Title\n",
+			opts:     []gemtext.Option{gemtext.KeepModelines()},
+			expected: "<!-- vim: set tw=72: -->\n\n# Title\n",
+		},
+		{
+			name:     "keep modeline followed by a single line break",
+			input:    "<!-- vim: set tw=72: -->\n# Title\n",
+			opts:     []gemtext.Option{gemtext.KeepModelines()},
+			expected: "<!-- vim: set tw=72: -->\n# Title\n",
+		},
+		{
+			name:     "keep modeline on the last line",
+			input:    "Some\ntext\n<!-- vim: set tw=72: -->\n",
+			opts:     []gemtext.Option{gemtext.KeepModelines()},
+			expected: "Some text\n<!-- vim: set tw=72: -->\n",
+		},
+		{
+			name:     "normalize paragraph separators",
+			input:    "# Title\n\n\n\nSome text\n\n\n    code\n\n\n    more code\n\n\n=> gemini://example.com\n\n\n> Quote\n\n\nEnd",
+			opts:     []gemtext.Option{gemtext.NormalizeParagraphSeparators()},
+			expected: "# Title\n\nSome text\n\n```\ncode\n\n\nmore code\n```\n\n=> gemini://example.com\n\n> Quote\n\nEnd",
+		},
+		{
+			name:     "clamp heading levels",
+			input:    "# One\n\n### Three\n\n###### Six",
+			opts:     []gemtext.Option{gemtext.MaxHeadingLevel(2)},
+			expected: "# One\n\n## Three\n\n## Six",
+		},
+		{
+			name:     "ensure single trailing newline",
+			input:    "Some text\n\n\n",
+			opts:     []gemtext.Option{gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesOne)},
+			expected: "Some text\n",
+		},
+		{
+			name:     "add missing trailing newline",
+			input:    "Some text",
+			opts:     []gemtext.Option{gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesOne)},
+			expected: "Some text\n",
+		},
+		{
+			name:     "remove trailing newlines",
+			input:    "Some text\n\nMore text\n\n",
+			opts:     []gemtext.Option{gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesNone)},
+			expected: "Some text\n\nMore text",
+		},
+		{
+			name:  "rewrite links",
+			input: "=> posts/hello.agmi Hello\n=> gemini://example.com/\n",
+			opts: []gemtext.Option{gemtext.RewriteLinks(func(uri string) string {
+				return strings.TrimSuffix(uri, ".agmi") + ".gmi"
+			})},
+			expected: "=> posts/hello.gmi Hello\n=> gemini://example.com/.gmi\n",
+		},
+		{
+			name:     "strict mode accepts valid input",
+			input:    "Some text\n\n* A list item\n",
+			opts:     []gemtext.Option{gemtext.Strict()},
+			expected: "Some text\n\n* A list item\n",
+		},
+		{
+			name:  "strict mode rejects invalid input",
+			input: "Some text\n* A list item\n",
+			opts:  []gemtext.Option{gemtext.Strict()},
+			err:   "2:1: list must be separated from the preceding paragraph by an empty line",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			err := gemtext.FromAlmostGemtextWithOptions(strings.NewReader(tt.input), &out, tt.opts...)
+			if tt.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+				assert.Empty(t, out.String())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestFromAlmostGemtext_Errors(t *testing.T) {
+	errWrite := errors.New("write failed")
+
+	tests := []struct {
+		name   string
+		input  string
+		out    io.Writer
+		opts   []gemtext.Option
+		target error
+		kind   agmi.SyntaxErrorKind
+		pos    agmi.Pos
+	}{
+		{
+			name:   "write error",
+			input:  "# Title\n",
+			out:    failingWriter{err: errWrite},
+			target: errWrite,
+		},
+		{
+			name:  "strict mode syntax error",
+			input: "Some text\n* A list item\n",
+			out:   &strings.Builder{},
+			opts:  []gemtext.Option{gemtext.Strict()},
+			kind:  agmi.SyntaxErrorListParagraph,
+			pos:   agmi.Pos{Offset: 10, Line: 2, Col: 1},
+		},
+		{
+			name:  "strict mode unclosed pre-formatted text",
+			input: "# Title\n\n```\ncode\n",
+			out:   &strings.Builder{},
+			opts:  []gemtext.Option{gemtext.Strict()},
+			kind:  agmi.SyntaxErrorUnclosedPreFmt,
+			pos:   agmi.Pos{Offset: 9, Line: 3, Col: 1},
+		},
+		{
+			name:  "strict mode link without URI",
+			input: "Text\n\n=>\n",
+			out:   &strings.Builder{},
+			opts:  []gemtext.Option{gemtext.Strict()},
+			kind:  agmi.SyntaxErrorLinkWithoutURI,
+			pos:   agmi.Pos{Offset: 6, Line: 3, Col: 1},
+		},
+		{
+			name:  "strict mode heading level",
+			input: "# Title\n\n####### Too deep\n",
+			out:   &strings.Builder{},
+			opts:  []gemtext.Option{gemtext.Strict()},
+			kind:  agmi.SyntaxErrorHeadingLevel,
+			pos:   agmi.Pos{Offset: 9, Line: 3, Col: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := gemtext.FromAlmostGemtextWithOptions(strings.NewReader(tt.input), tt.out, tt.opts...)
+			if !assert.Error(t, err) {
+				return
+			}
+			if tt.target != nil {
+				assert.True(t, errors.Is(err, tt.target), "expected %v in %v", tt.target, err)
+				return
+			}
+			var serr *agmi.SyntaxError
+			if assert.True(t, errors.As(err, &serr)) {
+				assert.Equal(t, tt.kind, serr.Kind)
+				assert.Equal(t, tt.pos, serr.Pos)
+			}
+		})
+	}
+}
+
+func TestFromAlmostGemtext_ErrorMessage(t *testing.T) {
+	err := gemtext.FromAlmostGemtext(strings.NewReader("# Title\n"), failingWriter{err: errors.New("write failed")})
+	assert.EqualError(t, err, "gemtext/FromAlmostGemtext: agmi/Converter.Format: agmi/Converter.Write: write failed")
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestFromAlmostGemtext_EmptyDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []gemtext.Option
+	}{
+		{
+			name:  "zero bytes",
+			input: "",
+		},
+		{
+			name:  "blank lines only",
+			input: "\n  \n\n\t\n\n",
+		},
+		{
+			name:  "modeline only",
+			input: "<!-- vim: set tw=72: -->\n",
+		},
+		{
+			name:  "modelines and blank lines",
+			input: "\n<!-- vim: set tw=72: -->\n\n\n<!-- vim: set ft=markdown: -->",
+		},
+		{
+			name:  "all options",
+			input: "\n\n",
+			opts: []gemtext.Option{
+				gemtext.NormalizeParagraphSeparators(),
+				gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesOne),
+				gemtext.Strict(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			err := gemtext.FromAlmostGemtextWithOptions(strings.NewReader(tt.input), &out, tt.opts...)
+			assert.NoError(t, err)
+			assert.Empty(t, out.String())
+		})
+	}
+}
+
+func TestFromAlmostGemtextWithOptions_GemtextConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", t.Name(), "*.gmi"))
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, files) {
+		return
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			input, err := os.ReadFile(file)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var out strings.Builder
+			err = gemtext.FromAlmostGemtextWithOptions(bytes.NewReader(input), &out, gemtext.GemtextCompatible())
+			assert.NoError(t, err)
+			assert.Equal(t, string(input), out.String())
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package gemtext
+
+import "github.com/fhofherr/mnml/format"
+
+// FormatName is the name under which Gemtext is registered with the format
+// package.
+const FormatName = "gemtext"
+
+func init() {
+	format.MustRegister(format.Format{
+		Name:      FormatName,
+		Extension: ".gmi",
+		MIMEType:  "text/gemini",
+		Convert:   FromAlmostGemtext,
+	})
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package gemtext_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/gemtext"
+	"github.com/fhofherr/mnml/internal/testsupport"
+)
+
+func FuzzFromAlmostGemtext(f *testing.F) {
+	testsupport.AddSeedCorpus(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var out bytes.Buffer
+
+		if err := gemtext.FromAlmostGemtext(bytes.NewReader(data), &out); err != nil {
+			t.Fatal(err)
+		}
+		if utf8.Valid(data) && !utf8.Valid(out.Bytes()) {
+			t.Fatalf("output is not valid UTF-8: %q", out.String())
+		}
+
+		// Every word of the input appears in the output in the same order.
+		// Only modelines may be dropped. Links defined for references are
+		// moved and numbered before.
+		output := out.String()
+		offset := 0
+		for _, word := range words(t, data) {
+			i := strings.Index(output[offset:], word)
+			if i < 0 {
+				t.Fatalf("%q missing in output after offset %d: %q", word, offset, output)
+			}
+			offset += i + len(word)
+		}
+	})
+}
+
+// words returns the words of all text and link URIs in data after numbering
+// the references to links.
+func words(t *testing.T, data []byte) []string {
+	var words []string
+
+	sc := agmi.ApplyFilters(agmi.NewScanner(bytes.NewReader(data)), agmi.ReferenceLinks())
+	for sc.Scan() {
+		tok := sc.Token()
+		if tok.Type == agmi.TokenTypeText || tok.Type == agmi.TokenTypeLinkURI {
+			words = append(words, strings.Fields(tok.Text)...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return words
+}
@@ -0,0 +1,95 @@
+package gemtext
+
+// Option changes the way FromAlmostGemtextWithOptions converts Almost
+// Gemtext to Gemtext.
+type Option func(*converter)
+
+// TrailingNewlines defines how newlines at the end of a document are
+// treated.
+type TrailingNewlines int
+
+const (
+	// TrailingNewlinesKeep copies the newlines at the end of the document
+	// to the output. This is the default.
+	TrailingNewlinesKeep TrailingNewlines = iota
+
+	// TrailingNewlinesOne ensures the output ends with exactly one newline.
+	TrailingNewlinesOne
+
+	// TrailingNewlinesNone removes all newlines from the end of the output.
+	TrailingNewlinesNone
+)
+
+// KeepModelines copies modelines to the output instead of dropping them.
+func KeepModelines() Option {
+	return func(g *converter) {
+		g.keepModelines = true
+	}
+}
+
+// NormalizeParagraphSeparators separates paragraphs by exactly one blank
+// line, regardless of the number of blank lines in the input.
+//
+// Blank lines within pre-formatted text are not changed.
+func NormalizeParagraphSeparators() Option {
+	return func(g *converter) {
+		g.normalizeParSep = true
+	}
+}
+
+// MaxHeadingLevel reduces the level of all headings exceeding level to
+// level.
+//
+// Gemtext only knows three levels of headings. MaxHeadingLevel(3) thus
+// ensures that the output only contains valid Gemtext headings.
+func MaxHeadingLevel(level int) Option {
+	return func(g *converter) {
+		if level < 1 {
+			level = 1
+		}
+		g.maxHeadingLevel = level
+	}
+}
+
+// WithTrailingNewlines sets the policy for newlines at the end of the
+// output.
+func WithTrailingNewlines(policy TrailingNewlines) Option {
+	return func(g *converter) {
+		g.trailingNewlines = policy
+	}
+}
+
+// RewriteLinks passes the URI of every link to rewrite and writes the
+// returned URI to the output instead.
+func RewriteLinks(rewrite func(uri string) string) Option {
+	return func(g *converter) {
+		g.rewriteLink = rewrite
+	}
+}
+
+// GemtextCompatible treats the input as Gemtext instead of Almost Gemtext.
+//
+// Almost Gemtext joins consecutive lines of text, list items, and quotes.
+// It also treats indented lines as pre-formatted text and drops modelines.
+// All of this changes plain Gemtext documents. In compatibility mode every
+// line of the input is copied to the output as is.
+//
+// Only RewriteLinks and WithTrailingNewlines are applied in compatibility
+// mode. All other options are ignored.
+func GemtextCompatible() Option {
+	return func(g *converter) {
+		g.gemtextCompatible = true
+	}
+}
+
+// Strict causes FromAlmostGemtextWithOptions to fail if the input violates
+// the Almost Gemtext specification. The returned error wraps a
+// *agmi.SyntaxError of one of the kinds reserved for strict mode, e.g.
+// agmi.SyntaxErrorListParagraph.
+//
+// In strict mode the whole input is read before any output is written.
+func Strict() Option {
+	return func(g *converter) {
+		g.strict = true
+	}
+}
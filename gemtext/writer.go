@@ -0,0 +1,54 @@
+package gemtext
+
+import (
+	"bytes"
+	"io"
+)
+
+// trailingNewlineWriter holds back newlines until it either receives
+// further output or is closed. Once closed it writes the newlines held back
+// according to its policy.
+type trailingNewlineWriter struct {
+	w        io.Writer
+	policy   TrailingNewlines
+	newlines int  // Number of newlines held back.
+	written  bool // Anything except newlines was written.
+}
+
+func (tw *trailingNewlineWriter) Write(p []byte) (int, error) {
+	if tw.policy == TrailingNewlinesKeep {
+		return tw.w.Write(p)
+	}
+	content := bytes.TrimRight(p, "\n")
+	if len(content) == 0 {
+		tw.newlines += len(p)
+		return len(p), nil
+	}
+	if err := tw.writeNewlines(tw.newlines); err != nil {
+		return 0, err
+	}
+	if _, err := tw.w.Write(content); err != nil {
+		return 0, err
+	}
+	tw.newlines = len(p) - len(content)
+	tw.written = true
+	return len(p), nil
+}
+
+// Close writes the newlines held back according to the policy of tw.
+//
+// Close does not close the underlying writer.
+func (tw *trailingNewlineWriter) Close() error {
+	if tw.policy == TrailingNewlinesOne && tw.written {
+		return tw.writeNewlines(1)
+	}
+	return nil
+}
+
+func (tw *trailingNewlineWriter) writeNewlines(n int) error {
+	if n == 0 {
+		return nil
+	}
+	_, err := tw.w.Write(bytes.Repeat([]byte("\n"), n))
+	return err
+}
@@ -0,0 +1,289 @@
+// Package gopher provides the building blocks for converting Almost Gemtext
+// to Gopher menus.
+package gopher
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ItemType is the type of an item within a Gopher menu.
+type ItemType byte
+
+// Item types used by Resolver.
+const (
+	ItemText   ItemType = '0' // Plain text file.
+	ItemMenu   ItemType = '1' // Gopher menu, e.g. a directory.
+	ItemError  ItemType = '3' // Error message.
+	ItemBinary ItemType = '9' // Binary file, e.g. an archive.
+	ItemGIF    ItemType = 'g' // GIF image.
+	ItemImage  ItemType = 'I' // Any other image.
+	ItemSound  ItemType = 's' // Sound file.
+	ItemHTML   ItemType = 'h' // HTML file or, with a URL: selector, any URL.
+	ItemInfo   ItemType = 'i' // Informational text without a link.
+)
+
+// DefaultPort is the port of Gopher servers if a URL specifies none.
+const DefaultPort = 70
+
+// URLPrefix starts the selector of items linking to URLs outside of
+// Gopherspace.
+const URLPrefix = "URL:"
+
+// itemTypes maps file extensions to the item types of files other than
+// documents.
+var itemTypes = map[string]ItemType{
+	".txt":  ItemText,
+	".text": ItemText,
+	".md":   ItemText,
+	".csv":  ItemText,
+	".gif":  ItemGIF,
+	".png":  ItemImage,
+	".jpg":  ItemImage,
+	".jpeg": ItemImage,
+	".bmp":  ItemImage,
+	".webp": ItemImage,
+	".svg":  ItemImage,
+	".html": ItemHTML,
+	".htm":  ItemHTML,
+	".wav":  ItemSound,
+	".mp3":  ItemSound,
+	".ogg":  ItemSound,
+	".flac": ItemSound,
+	".zip":  ItemBinary,
+	".tar":  ItemBinary,
+	".gz":   ItemBinary,
+	".tgz":  ItemBinary,
+	".bz2":  ItemBinary,
+	".xz":   ItemBinary,
+	".7z":   ItemBinary,
+	".rar":  ItemBinary,
+}
+
+// Link is the target of a Gopher menu item.
+type Link struct {
+	Type     ItemType
+	Selector string
+	Host     string // Empty for ItemInfo.
+	Port     int    // Zero for ItemInfo.
+}
+
+// menuFieldReplacer replaces the characters separating the fields and
+// lines of a Gopher menu.
+var menuFieldReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// MenuLine returns the line of a Gopher menu linking to l and labelled
+// with display. The line ends with CR LF.
+//
+// Tabs, carriage returns, and line feeds within display or the selector of
+// l are replaced by spaces. Lines of ItemInfo items use the placeholders
+// common in Gopherspace: the selector fake, the host (NULL), and port 0.
+func (l Link) MenuLine(display string) string {
+	selector, host, port := l.Selector, l.Host, l.Port
+	if l.Type == ItemInfo {
+		selector, host, port = "fake", "(NULL)", 0
+	}
+	return fmt.Sprintf("%c%s\t%s\t%s\t%d\r\n",
+		l.Type, menuFieldReplacer.Replace(display), menuFieldReplacer.Replace(selector), host, port)
+}
+
+// GeminiConvention defines how a Resolver links to gemini:// URLs.
+type GeminiConvention int
+
+const (
+	// GeminiURL links to gemini:// URLs with an ItemHTML item and a URL:
+	// selector. Many modern clients open such items with the appropriate
+	// program.
+	GeminiURL GeminiConvention = iota
+
+	// GeminiMirror assumes every capsule is mirrored in Gopherspace on the
+	// same host and path. It links to the menu with the selector of the
+	// path of the gemini:// URL on the default port.
+	GeminiMirror
+
+	// GeminiInfo does not link to gemini:// URLs at all. The Resolver
+	// returns an ItemInfo item instead. Callers usually display the URL.
+	GeminiInfo
+)
+
+// Resolver chooses the item type and selector of the Gopher menu items
+// created from Almost Gemtext links.
+//
+// Relative links point to files of the site served by Host on Port.
+// Links to Almost Gemtext and Gemtext documents point to the Gopher
+// documents created from them. Those are menus unless IsText returns true
+// for them. Links to other files are typed by their extension. Links to
+// directories, i.e. paths ending in a slash or without an extension, point
+// to menus.
+//
+// Links to gopher:// URLs are split into their components. Links to
+// gemini:// URLs follow Gemini. Links using any other scheme, e.g. http://
+// or https://, point to an ItemHTML item with a URL: selector.
+type Resolver struct {
+	Host string // Host serving the site.
+	Port int    // Port serving the site. Defaults to DefaultPort.
+	Root string // Selector of the root of the site, e.g. /~me. Defaults to the empty selector.
+
+	MenuExtension string // Extension of menus created from documents. Defaults to .gph.
+	TextExtension string // Extension of text files created from documents. Defaults to .txt.
+
+	// IsText returns true if the Almost Gemtext document at path becomes a
+	// text file instead of a menu. Path is relative to the root of the
+	// site and separated by slashes. If IsText is nil all documents become
+	// menus.
+	IsText func(path string) bool
+
+	Gemini GeminiConvention // How to link to gemini:// URLs.
+}
+
+// Resolve returns the target of the menu item for a link to uri within the
+// document at base. Base is relative to the root of the site and separated
+// by slashes.
+func (r *Resolver) Resolve(base, uri string) (Link, error) {
+	const op = "gopher/Resolver.Resolve"
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+	var l Link
+	switch {
+	case u.Scheme == "" && u.Host == "":
+		l, err = r.resolveLocal(base, u)
+	case u.Scheme == "gopher":
+		l, err = parseGopherURL(u)
+	case u.Scheme == "gemini":
+		l = r.resolveGemini(u)
+	default:
+		l = r.urlLink(u.String())
+	}
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return l, nil
+}
+
+// resolveLocal resolves a link to a file of the site.
+func (r *Resolver) resolveLocal(base string, u *url.URL) (Link, error) {
+	p := u.Path
+	dir := strings.HasSuffix(p, "/")
+	switch {
+	case p == "":
+		p = base
+	case strings.HasPrefix(p, "/"):
+		p = path.Clean(p)
+	default:
+		p = path.Join(path.Dir(base), p)
+	}
+	if strings.HasPrefix(p, "../") || p == ".." {
+		return Link{}, fmt.Errorf("%s: outside of the site", u)
+	}
+	p = strings.TrimPrefix(p, "/")
+	if p == "." {
+		p = ""
+	}
+
+	ext := strings.ToLower(path.Ext(p))
+	l := Link{Host: r.Host, Port: r.port()}
+	switch {
+	case u.Path == "":
+		// Link to the document itself, e.g. to a fragment.
+		l.Type, p = r.document(p)
+	case dir || ext == "":
+		l.Type = ItemMenu
+	case ext == ".agmi" || ext == ".gmi":
+		l.Type, p = r.document(p)
+	default:
+		t, ok := itemTypes[ext]
+		if !ok {
+			t = ItemBinary
+		}
+		l.Type = t
+	}
+	l.Selector = strings.TrimSuffix(r.Root, "/") + "/" + p
+	if dir && p != "" {
+		l.Selector += "/"
+	}
+	return l, nil
+}
+
+// document returns the item type and the path of the Gopher document
+// created from the document at p.
+func (r *Resolver) document(p string) (ItemType, string) {
+	base := strings.TrimSuffix(p, path.Ext(p))
+	if r.IsText != nil && r.IsText(p) {
+		return ItemText, base + defaultString(r.TextExtension, ".txt")
+	}
+	return ItemMenu, base + defaultString(r.MenuExtension, ".gph")
+}
+
+// resolveGemini resolves a link to a gemini:// URL according to r.Gemini.
+func (r *Resolver) resolveGemini(u *url.URL) Link {
+	switch r.Gemini {
+	case GeminiMirror:
+		return Link{Type: ItemMenu, Selector: u.Path, Host: u.Hostname(), Port: DefaultPort}
+	case GeminiInfo:
+		return Link{Type: ItemInfo}
+	default:
+		return r.urlLink(u.String())
+	}
+}
+
+// urlLink returns a link to uri outside of Gopherspace.
+func (r *Resolver) urlLink(uri string) Link {
+	return Link{Type: ItemHTML, Selector: URLPrefix + uri, Host: r.Host, Port: r.port()}
+}
+
+func (r *Resolver) port() int {
+	if r.Port > 0 {
+		return r.Port
+	}
+	return DefaultPort
+}
+
+// parseGopherURL splits the gopher:// URL u into its components as defined
+// by RFC 4266. The first character of the path is the item type, the
+// remainder the selector. A URL without a path refers to the root menu.
+func parseGopherURL(u *url.URL) (Link, error) {
+	if u.Hostname() == "" {
+		return Link{}, fmt.Errorf("%s: missing host", u)
+	}
+	l := Link{Type: ItemMenu, Host: u.Hostname(), Port: DefaultPort}
+	if s := u.Port(); s != "" {
+		port, err := strconv.Atoi(s)
+		if err != nil || port < 1 || port > 65535 {
+			return Link{}, fmt.Errorf("%s: invalid port", u)
+		}
+		l.Port = port
+	}
+	// A question mark is part of the selector. The whole path is
+	// percent-decoded, including the part following the question mark.
+	p := strings.TrimPrefix(u.EscapedPath(), "/")
+	if u.RawQuery != "" || u.ForceQuery {
+		p += "?" + u.RawQuery
+	}
+	p, err := url.PathUnescape(p)
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: invalid selector", u)
+	}
+	if p == "" {
+		return l, nil
+	}
+	l.Type = ItemType(p[0])
+	l.Selector = p[1:]
+	// A tab separates the selector from a search string.
+	if i := strings.IndexByte(l.Selector, '\t'); i >= 0 {
+		l.Selector = l.Selector[:i]
+	}
+	return l, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
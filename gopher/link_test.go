@@ -0,0 +1,410 @@
+package gopher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fhofherr/mnml/gopher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	site := func(typ gopher.ItemType, selector string) gopher.Link {
+		return gopher.Link{Type: typ, Selector: selector, Host: "example.com", Port: gopher.DefaultPort}
+	}
+	tests := []struct {
+		name     string
+		resolver gopher.Resolver
+		base     string
+		uri      string
+		expected gopher.Link
+		err      string
+	}{
+		{
+			name:     "document in the same directory",
+			base:     "posts/first.agmi",
+			uri:      "second.agmi",
+			expected: site(gopher.ItemMenu, "/posts/second.gph"),
+		},
+		{
+			name:     "gemtext document",
+			base:     "index.agmi",
+			uri:      "about.gmi",
+			expected: site(gopher.ItemMenu, "/about.gph"),
+		},
+		{
+			name:     "document in the parent directory",
+			base:     "posts/2021/first.agmi",
+			uri:      "../index.agmi",
+			expected: site(gopher.ItemMenu, "/posts/index.gph"),
+		},
+		{
+			name:     "document relative to the root of the site",
+			base:     "posts/first.agmi",
+			uri:      "/about.agmi",
+			expected: site(gopher.ItemMenu, "/about.gph"),
+		},
+		{
+			name:     "document with upper case extension",
+			base:     "index.agmi",
+			uri:      "README.AGMI",
+			expected: site(gopher.ItemMenu, "/README.gph"),
+		},
+		{
+			name:     "document with fragment",
+			base:     "index.agmi",
+			uri:      "about.agmi#contact",
+			expected: site(gopher.ItemMenu, "/about.gph"),
+		},
+		{
+			name:     "fragment of the document itself",
+			base:     "posts/first.agmi",
+			uri:      "#intro",
+			expected: site(gopher.ItemMenu, "/posts/first.gph"),
+		},
+		{
+			name: "document becoming a text file",
+			resolver: gopher.Resolver{
+				IsText: func(p string) bool { return strings.HasPrefix(p, "posts/") },
+			},
+			base:     "index.agmi",
+			uri:      "posts/first.agmi",
+			expected: site(gopher.ItemText, "/posts/first.txt"),
+		},
+		{
+			name: "document becoming a menu next to text files",
+			resolver: gopher.Resolver{
+				IsText: func(p string) bool { return strings.HasPrefix(p, "posts/") },
+			},
+			base:     "posts/first.agmi",
+			uri:      "../index.agmi",
+			expected: site(gopher.ItemMenu, "/index.gph"),
+		},
+		{
+			name: "custom extensions",
+			resolver: gopher.Resolver{
+				MenuExtension: ".map",
+				TextExtension: ".text",
+				IsText:        func(p string) bool { return p == "notes.agmi" },
+			},
+			base:     "index.agmi",
+			uri:      "notes.agmi",
+			expected: site(gopher.ItemText, "/notes.text"),
+		},
+		{
+			name:     "site below a root selector",
+			resolver: gopher.Resolver{Root: "/~me/"},
+			base:     "posts/first.agmi",
+			uri:      "second.agmi",
+			expected: site(gopher.ItemMenu, "/~me/posts/second.gph"),
+		},
+		{
+			name:     "custom port",
+			resolver: gopher.Resolver{Port: 7070},
+			base:     "index.agmi",
+			uri:      "about.agmi",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/about.gph", Host: "example.com", Port: 7070},
+		},
+		{
+			name:     "text file",
+			base:     "index.agmi",
+			uri:      "notes/todo.txt",
+			expected: site(gopher.ItemText, "/notes/todo.txt"),
+		},
+		{
+			name:     "markdown file",
+			base:     "index.agmi",
+			uri:      "README.md",
+			expected: site(gopher.ItemText, "/README.md"),
+		},
+		{
+			name:     "gif image",
+			base:     "index.agmi",
+			uri:      "images/cat.gif",
+			expected: site(gopher.ItemGIF, "/images/cat.gif"),
+		},
+		{
+			name:     "png image",
+			base:     "index.agmi",
+			uri:      "images/cat.png",
+			expected: site(gopher.ItemImage, "/images/cat.png"),
+		},
+		{
+			name:     "jpeg image with upper case extension",
+			base:     "index.agmi",
+			uri:      "images/cat.JPG",
+			expected: site(gopher.ItemImage, "/images/cat.JPG"),
+		},
+		{
+			name:     "zip archive",
+			base:     "index.agmi",
+			uri:      "downloads/mnml.zip",
+			expected: site(gopher.ItemBinary, "/downloads/mnml.zip"),
+		},
+		{
+			name:     "compressed tarball",
+			base:     "index.agmi",
+			uri:      "downloads/mnml.tar.gz",
+			expected: site(gopher.ItemBinary, "/downloads/mnml.tar.gz"),
+		},
+		{
+			name:     "unknown extension",
+			base:     "index.agmi",
+			uri:      "paper.pdf",
+			expected: site(gopher.ItemBinary, "/paper.pdf"),
+		},
+		{
+			name:     "sound file",
+			base:     "index.agmi",
+			uri:      "podcast/episode1.mp3",
+			expected: site(gopher.ItemSound, "/podcast/episode1.mp3"),
+		},
+		{
+			name:     "local html file",
+			base:     "index.agmi",
+			uri:      "legacy/index.html",
+			expected: site(gopher.ItemHTML, "/legacy/index.html"),
+		},
+		{
+			name:     "directory with trailing slash",
+			base:     "index.agmi",
+			uri:      "posts/",
+			expected: site(gopher.ItemMenu, "/posts/"),
+		},
+		{
+			name:     "directory without extension",
+			base:     "index.agmi",
+			uri:      "posts",
+			expected: site(gopher.ItemMenu, "/posts"),
+		},
+		{
+			name:     "root directory",
+			base:     "posts/first.agmi",
+			uri:      "/",
+			expected: site(gopher.ItemMenu, "/"),
+		},
+		{
+			name:     "parent directory",
+			base:     "posts/2021/first.agmi",
+			uri:      "../",
+			expected: site(gopher.ItemMenu, "/posts/"),
+		},
+		{
+			name:     "root directory below a root selector",
+			resolver: gopher.Resolver{Root: "/~me"},
+			base:     "index.agmi",
+			uri:      "/",
+			expected: site(gopher.ItemMenu, "/~me/"),
+		},
+		{
+			name: "link outside of the site",
+			base: "index.agmi",
+			uri:  "../secret.txt",
+			err:  "../secret.txt: outside of the site",
+		},
+		{
+			name:     "http link",
+			base:     "index.agmi",
+			uri:      "http://example.org/",
+			expected: site(gopher.ItemHTML, "URL:http://example.org/"),
+		},
+		{
+			name:     "https link with query",
+			base:     "index.agmi",
+			uri:      "https://example.org/search?q=gopher",
+			expected: site(gopher.ItemHTML, "URL:https://example.org/search?q=gopher"),
+		},
+		{
+			name:     "https link on a custom port",
+			resolver: gopher.Resolver{Port: 7070},
+			base:     "index.agmi",
+			uri:      "https://example.org/",
+			expected: gopher.Link{Type: gopher.ItemHTML, Selector: "URL:https://example.org/", Host: "example.com", Port: 7070},
+		},
+		{
+			name:     "mailto link",
+			base:     "index.agmi",
+			uri:      "mailto:me@example.com",
+			expected: site(gopher.ItemHTML, "URL:mailto:me@example.com"),
+		},
+		{
+			name:     "gemini link as URL",
+			base:     "index.agmi",
+			uri:      "gemini://example.org/posts/",
+			expected: site(gopher.ItemHTML, "URL:gemini://example.org/posts/"),
+		},
+		{
+			name:     "gemini link to a mirror",
+			resolver: gopher.Resolver{Gemini: gopher.GeminiMirror},
+			base:     "index.agmi",
+			uri:      "gemini://example.org/posts/",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/posts/", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gemini link to a mirror ignores the port",
+			resolver: gopher.Resolver{Gemini: gopher.GeminiMirror},
+			base:     "index.agmi",
+			uri:      "gemini://example.org:1965/",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gemini link as info",
+			resolver: gopher.Resolver{Gemini: gopher.GeminiInfo},
+			base:     "index.agmi",
+			uri:      "gemini://example.org/",
+			expected: gopher.Link{Type: gopher.ItemInfo},
+		},
+		{
+			name:     "gopher link to the root menu",
+			base:     "index.agmi",
+			uri:      "gopher://gopher.floodgap.com",
+			expected: gopher.Link{Type: gopher.ItemMenu, Host: "gopher.floodgap.com", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link to the root menu with slash",
+			base:     "index.agmi",
+			uri:      "gopher://gopher.floodgap.com/",
+			expected: gopher.Link{Type: gopher.ItemMenu, Host: "gopher.floodgap.com", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link to a menu",
+			base:     "index.agmi",
+			uri:      "gopher://gopher.floodgap.com/1/world",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/world", Host: "gopher.floodgap.com", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link to a text file",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/0/about.txt",
+			expected: gopher.Link{Type: gopher.ItemText, Selector: "/about.txt", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link with port",
+			base:     "index.agmi",
+			uri:      "gopher://example.org:7070/9/mnml.zip",
+			expected: gopher.Link{Type: gopher.ItemBinary, Selector: "/mnml.zip", Host: "example.org", Port: 7070},
+		},
+		{
+			name:     "gopher link with selector without slash",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/0about",
+			expected: gopher.Link{Type: gopher.ItemText, Selector: "about", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link with escaped selector",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/1/my%20menu",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/my menu", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link with question mark in selector",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/1/cgi?page=2",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "/cgi?page=2", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link with escaped question mark and query",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/1a%20b?c%20d%3F",
+			expected: gopher.Link{Type: gopher.ItemMenu, Selector: "a b?c d?", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name: "gopher link with invalid escape in query",
+			base: "index.agmi",
+			uri:  "gopher://example.org/1a?%zz",
+			err:  "gopher/Resolver.Resolve: gopher://example.org/1a?%zz: invalid selector",
+		},
+		{
+			name:     "gopher link with search string",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/7/search%09mnml",
+			expected: gopher.Link{Type: '7', Selector: "/search", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link to an image",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/I/cat.png",
+			expected: gopher.Link{Type: gopher.ItemImage, Selector: "/cat.png", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name:     "gopher link to a URL",
+			base:     "index.agmi",
+			uri:      "gopher://example.org/hURL:https://example.org/",
+			expected: gopher.Link{Type: gopher.ItemHTML, Selector: "URL:https://example.org/", Host: "example.org", Port: gopher.DefaultPort},
+		},
+		{
+			name: "gopher link without host",
+			base: "index.agmi",
+			uri:  "gopher:///1/",
+			err:  "gopher/Resolver.Resolve: gopher:///1/: missing host",
+		},
+		{
+			name: "gopher link with invalid port",
+			base: "index.agmi",
+			uri:  "gopher://example.org:99999/1/",
+			err:  "gopher/Resolver.Resolve: gopher://example.org:99999/1/: invalid port",
+		},
+		{
+			name: "invalid URL",
+			base: "index.agmi",
+			uri:  "http://example.org/%zz",
+			err:  "gopher/Resolver.Resolve: parse",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.resolver
+			r.Host = "example.com"
+			actual, err := r.Resolve(tt.base, tt.uri)
+			if tt.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestLink_MenuLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     gopher.Link
+		display  string
+		expected string
+	}{
+		{
+			name:     "menu",
+			link:     gopher.Link{Type: gopher.ItemMenu, Selector: "/posts/", Host: "example.com", Port: 70},
+			display:  "Posts",
+			expected: "1Posts\t/posts/\texample.com\t70\r\n",
+		},
+		{
+			name:     "URL",
+			link:     gopher.Link{Type: gopher.ItemHTML, Selector: "URL:https://example.org/", Host: "example.com", Port: 7070},
+			display:  "Example",
+			expected: "hExample\tURL:https://example.org/\texample.com\t7070\r\n",
+		},
+		{
+			name:     "info",
+			link:     gopher.Link{Type: gopher.ItemInfo},
+			display:  "gemini://example.org/",
+			expected: "igemini://example.org/\tfake\t(NULL)\t0\r\n",
+		},
+		{
+			name:     "tabs and line breaks",
+			link:     gopher.Link{Type: gopher.ItemText, Selector: "/a\tb\r\n.txt", Host: "example.com", Port: 70},
+			display:  "First\tline\r\nsecond line",
+			expected: "0First line  second line\t/a b  .txt\texample.com\t70\r\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.link.MenuLine(tt.display))
+		})
+	}
+}
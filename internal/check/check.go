@@ -0,0 +1,305 @@
+// Package check finds problems in Almost Gemtext documents.
+//
+// The problems found by this package do not stop mnml from converting a
+// document. They usually lead to output that differs from what the author
+// intended, though.
+package check
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fhofherr/mnml/agmi"
+)
+
+// Severity defines how severe a problem reported by a Diagnostic is.
+type Severity string
+
+const (
+	// SeverityError marks violations of the Almost Gemtext specification.
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks constructs that are valid Almost Gemtext but
+	// most probably do not produce the intended output.
+	SeverityWarning Severity = "warning"
+)
+
+// Names of the rules checked by Document. Errors are named like the kinds
+// of the SyntaxErrors returned by converters in strict mode.
+const (
+	RuleListParagraph   = string(agmi.SyntaxErrorListParagraph)  // Lists must have a paragraph of their own.
+	RuleListIndent      = "list-indent"                          // List items continue with an indent of two spaces.
+	RuleUnclosedPreFmt  = string(agmi.SyntaxErrorUnclosedPreFmt) // Pre-formatted text started with ``` must be closed.
+	RuleLinkWithoutURI  = string(agmi.SyntaxErrorLinkWithoutURI) // Links must have an URI.
+	RuleHeadingLevel    = string(agmi.SyntaxErrorHeadingLevel)   // Headings have at most six levels.
+	RuleEmptyDocument   = "empty-document"                       // Documents should have content besides modelines and blank lines.
+	RuleUnclosedComment = "unclosed-comment"                     // Comments should be closed with -->.
+	RuleUndefinedRef    = "undefined-ref"                        // References to links must be defined.
+	RuleUnusedRef       = "unused-ref"                           // Links defined for references should be used.
+)
+
+const (
+	maxHeadingLevel        = 6
+	listContinuationIndent = "  "
+)
+
+// Diagnostic describes a single problem found in a document.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Offset   int      `json:"-"` // Offset in bytes, used for positions of strict mode errors.
+	Line     int      `json:"line"`
+	Col      int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+// String formats the Diagnostic as file:line:col: severity: message.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.File, d.Line, d.Col, d.Severity, d.Message, d.Rule)
+}
+
+// Document checks the Almost Gemtext document read from r for problems.
+//
+// The name of the document is used as the File of every Diagnostic
+// returned. Document returns an error only if reading the document fails.
+func Document(name string, r io.Reader) ([]Diagnostic, error) {
+	const op = "check/Document"
+
+	l := linter{name: name, lineStart: true}
+	ts := agmi.ApplyFilters(agmi.NewScanner(r), l.checkComments, agmi.StripComments(), l.checkReferences)
+	for ts.Scan() {
+		l.check(ts.Token())
+	}
+	if err := ts.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	l.finish()
+	return l.diags, nil
+}
+
+// HasErrors returns true if any of diags has SeverityError.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	name  string
+	diags []Diagnostic
+
+	prev      agmi.Token // Token checked before the current one.
+	prevLine  agmi.Token // First token of the previous line.
+	lineStart bool       // The current token is the first of its line.
+	skipLine  bool       // Ignore the remaining tokens of the current line.
+	preFmt    agmi.Token // Opening ``` if inside of pre-formatted text.
+	list      bool       // Inside a list.
+	content   bool       // Anything but modelines and blank lines was found.
+}
+
+// checkComments reports comments which are never closed. Comments are
+// removed from the TokenStream afterwards. They are not checked any further.
+func (l *linter) checkComments(ts agmi.TokenStream) agmi.TokenStream {
+	var open agmi.Token // First Token of a comment which is not closed yet.
+
+	return agmi.FilterTokens(ts, func(tok agmi.Token, emit func(agmi.Token)) error {
+		switch {
+		case tok.Type == agmi.TokenTypeComment:
+			if open.IsZero() {
+				open = tok
+			}
+			if strings.HasSuffix(tok.Text, "-->") {
+				open = agmi.Token{}
+			}
+		case tok.IsZero() && !open.IsZero():
+			l.report(open, SeverityWarning, RuleUnclosedComment,
+				"comment is never closed and extends to the end of the document")
+		}
+		if !tok.IsZero() {
+			emit(tok)
+		}
+		return nil
+	})
+}
+
+// checkReferences reports references to links which are never defined and
+// links defined for references which are never used.
+func (l *linter) checkReferences(ts agmi.TokenStream) agmi.TokenStream {
+	var (
+		lineStart = true
+		preFmt    bool
+		link      bool // Within a link.
+		uri       bool // The link has an URI.
+		defs      []ref
+		uses      []ref
+	)
+
+	return agmi.FilterTokens(ts, func(tok agmi.Token, emit func(agmi.Token)) error {
+		switch {
+		case tok.IsZero():
+			l.reportReferences(defs, uses)
+			return nil
+		case lineStart && tok.Type == agmi.TokenTypePreFmtMod:
+			preFmt = !preFmt
+		case preFmt:
+		case lineStart && tok.Type == agmi.TokenTypeLinkMod:
+			link, uri = true, false
+		case link && tok.Type == agmi.TokenTypeLinkURI:
+			uri = true
+		case link && tok.Type == agmi.TokenTypeText:
+			if idx := agmi.RefDefinition.FindStringSubmatchIndex(tok.Text); uri && idx != nil {
+				defs = append(defs, newRef(tok, idx))
+			}
+			link = false
+		case tok.Type == agmi.TokenTypeText:
+			for _, idx := range agmi.RefMarker.FindAllStringSubmatchIndex(tok.Text, -1) {
+				uses = append(uses, newRef(tok, idx))
+			}
+		}
+		lineStart = tok.Type == agmi.TokenTypeLineBreak || tok.Type == agmi.TokenTypeParSep ||
+			tok.Type == agmi.TokenTypeHardBreak
+		if lineStart {
+			link = false
+		}
+		emit(tok)
+		return nil
+	})
+}
+
+// ref is a reference to a link or the definition of the link.
+type ref struct {
+	name string
+	tok  agmi.Token // Token positioned at the reference.
+}
+
+// newRef creates the ref matched by agmi.RefMarker or agmi.RefDefinition
+// within the Text of tok. The first submatch of idx must be the name of the
+// reference.
+func newRef(tok agmi.Token, idx []int) ref {
+	start := idx[2] - len("[^")
+	tok.Pos.Col += utf8.RuneCountInString(tok.Text[:start])
+	tok.Pos.Offset += start
+	return ref{name: tok.Text[idx[2]:idx[3]], tok: tok}
+}
+
+// reportReferences reports all uses of references which are not defined
+// and all definitions which are never used.
+func (l *linter) reportReferences(defs, uses []ref) {
+	defined := make(map[string]bool, len(defs))
+	for _, d := range defs {
+		defined[d.name] = true
+	}
+	used := make(map[string]bool, len(uses))
+	for _, u := range uses {
+		used[u.name] = true
+		if !defined[u.name] {
+			l.report(u.tok, SeverityWarning, RuleUndefinedRef,
+				fmt.Sprintf("reference [^%s] is never defined", u.name))
+		}
+	}
+	for _, d := range defs {
+		if !used[d.name] {
+			l.report(d.tok, SeverityWarning, RuleUnusedRef,
+				fmt.Sprintf("link for reference [^%s] is never used", d.name))
+		}
+	}
+}
+
+func (l *linter) check(tok agmi.Token) {
+	if !tok.IsBlank() && !(l.lineStart && tok.Type == agmi.TokenTypeModeline) {
+		l.content = true
+	}
+	if l.prev.Type == agmi.TokenTypeLinkMod && tok.Type != agmi.TokenTypeLinkURI {
+		l.report(l.prev, SeverityError, RuleLinkWithoutURI, "link has no URI")
+	}
+	switch {
+	case tok.Type == agmi.TokenTypeLineBreak || tok.Type == agmi.TokenTypeParSep || tok.Type == agmi.TokenTypeHardBreak:
+		if tok.Type == agmi.TokenTypeParSep {
+			l.list = false
+		}
+		l.skipLine = false
+		l.lineStart = true
+		l.prev = tok
+		return
+	case l.lineStart && tok.Type == agmi.TokenTypePreFmtMod:
+		if l.preFmt.IsZero() {
+			l.preFmt = tok
+		} else {
+			l.preFmt = agmi.Token{}
+		}
+		l.skipLine = true
+	case !l.preFmt.IsZero() || l.skipLine:
+		// Nothing to check within pre-formatted text.
+	case l.lineStart:
+		l.checkLineStart(tok)
+	}
+	if l.lineStart {
+		l.prevLine = tok
+	}
+	l.lineStart = false
+	l.prev = tok
+}
+
+func (l *linter) checkLineStart(tok agmi.Token) {
+	switch tok.Type {
+	case agmi.TokenTypeBulletPoint:
+		if !l.list && (l.prev.Type == agmi.TokenTypeLineBreak || l.prev.Type == agmi.TokenTypeHardBreak) &&
+			l.prevLine.Type != agmi.TokenTypeModeline {
+			l.report(tok, SeverityError, RuleListParagraph,
+				"list must be separated from the preceding paragraph by an empty line")
+		}
+		l.list = true
+	case agmi.TokenTypeIndent:
+		if l.list && tok.Text != listContinuationIndent {
+			l.report(tok, SeverityWarning, RuleListIndent,
+				"continuation lines of list items must be indented by exactly two spaces")
+		}
+		// The remainder of the line is either pre-formatted text or the
+		// continuation of a list item.
+		l.skipLine = true
+	default:
+		if l.list {
+			l.report(tok, SeverityError, RuleListParagraph,
+				"list must be separated from the following paragraph by an empty line")
+			l.list = false
+		}
+		if tok.Type == agmi.TokenTypeHeadingMod {
+			level := len(strings.TrimRight(tok.Text, " \t"))
+			if level > maxHeadingLevel {
+				l.report(tok, SeverityError, RuleHeadingLevel,
+					fmt.Sprintf("heading level %d exceeds the maximum of %d", level, maxHeadingLevel))
+			}
+		}
+	}
+}
+
+func (l *linter) finish() {
+	if !l.content {
+		l.report(agmi.Token{Pos: agmi.Pos{Line: 1, Col: 1}}, SeverityWarning, RuleEmptyDocument,
+			"document is empty and converts to empty output")
+	}
+	if l.prev.Type == agmi.TokenTypeLinkMod {
+		l.report(l.prev, SeverityError, RuleLinkWithoutURI, "link has no URI")
+	}
+	if !l.preFmt.IsZero() {
+		l.report(l.preFmt, SeverityError, RuleUnclosedPreFmt, "pre-formatted text is never closed")
+	}
+}
+
+func (l *linter) report(tok agmi.Token, sev Severity, rule, msg string) {
+	l.diags = append(l.diags, Diagnostic{
+		File:     l.name,
+		Offset:   tok.Pos.Offset,
+		Line:     tok.Pos.Line,
+		Col:      tok.Pos.Col,
+		Severity: sev,
+		Rule:     rule,
+		Message:  msg,
+	})
+}
@@ -0,0 +1,146 @@
+package check_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []check.Diagnostic
+	}{
+		{
+			name:  "valid document",
+			input: "# Title\n\nSome text\nspanning lines.\n\n* A list item\n  continued\n* Another item\n\n=> gemini://example.com Link\n",
+		},
+		{
+			name:  "list continuation indented by three spaces",
+			input: "* A list item\n   continued\n",
+			expected: []check.Diagnostic{
+				{Line: 2, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleListIndent},
+			},
+		},
+		{
+			name:  "list directly after paragraph",
+			input: "Some text\n* A list item\n",
+			expected: []check.Diagnostic{
+				{Line: 2, Col: 1, Severity: check.SeverityError, Rule: check.RuleListParagraph},
+			},
+		},
+		{
+			name:  "paragraph directly after list",
+			input: "* A list item\nSome text\n",
+			expected: []check.Diagnostic{
+				{Line: 2, Col: 1, Severity: check.SeverityError, Rule: check.RuleListParagraph},
+			},
+		},
+		{
+			name:  "list after modeline",
+			input: "<!-- vim: set tw=72: -->\n* A list item\n",
+		},
+		{
+			name:  "unclosed pre-formatted text",
+			input: "Some text\n\n```\n* not a list\n",
+			expected: []check.Diagnostic{
+				{Line: 3, Col: 1, Severity: check.SeverityError, Rule: check.RuleUnclosedPreFmt},
+			},
+		},
+		{
+			name:  "pre-formatted text by indent",
+			input: "Some text\n\n    * not a list\n",
+		},
+		{
+			name:  "link without URI",
+			input: "Some text\n\n=> \n\n=>",
+			expected: []check.Diagnostic{
+				{Line: 3, Col: 1, Severity: check.SeverityError, Rule: check.RuleLinkWithoutURI},
+				{Line: 5, Col: 1, Severity: check.SeverityError, Rule: check.RuleLinkWithoutURI},
+			},
+		},
+		{
+			name:  "heading level too deep",
+			input: "###### Fine\n\n####### Too deep\n",
+			expected: []check.Diagnostic{
+				{Line: 3, Col: 1, Severity: check.SeverityError, Rule: check.RuleHeadingLevel},
+			},
+		},
+		{
+			name:  "empty document",
+			input: "",
+			expected: []check.Diagnostic{
+				{Line: 1, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleEmptyDocument},
+			},
+		},
+		{
+			name:  "blank lines only",
+			input: "\n  \n\n\t\n",
+			expected: []check.Diagnostic{
+				{Line: 1, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleEmptyDocument},
+			},
+		},
+		{
+			name:  "modelines only",
+			input: "<!-- vim: set tw=72: -->\n\n<!-- vim: set ft=markdown: -->\n",
+			expected: []check.Diagnostic{
+				{Line: 1, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleEmptyDocument},
+			},
+		},
+		{
+			name:  "unclosed comment",
+			input: "# Title\n\n<!-- TODO\n\nText\n",
+			expected: []check.Diagnostic{
+				{Line: 3, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleUnclosedComment},
+			},
+		},
+		{
+			name:  "comments only",
+			input: "\n<!-- a -->\n<!-- b\n-->\n<!-- c -->\n",
+			expected: []check.Diagnostic{
+				{Line: 1, Col: 1, Severity: check.SeverityWarning, Rule: check.RuleEmptyDocument},
+			},
+		},
+		{
+			name:  "list directly after comment",
+			input: "Text\n<!-- a -->\n* Item\n",
+			expected: []check.Diagnostic{
+				{Line: 3, Col: 1, Severity: check.SeverityError, Rule: check.RuleListParagraph},
+			},
+		},
+		{
+			name:  "reference links",
+			input: "See [^a] and [^b].\n\n```\n[^c]\n```\n\n=> gemini://a.example [^a] A\n=> gemini://u.example [^unused]\n",
+			expected: []check.Diagnostic{
+				{Line: 1, Col: 14, Severity: check.SeverityWarning, Rule: check.RuleUndefinedRef},
+				{Line: 8, Col: 23, Severity: check.SeverityWarning, Rule: check.RuleUnusedRef},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			diags, err := check.Document("test.agmi", strings.NewReader(tt.input))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Len(t, diags, len(tt.expected)) {
+				return
+			}
+			for i, expected := range tt.expected {
+				actual := diags[i]
+				assert.Equal(t, "test.agmi", actual.File)
+				assert.Equal(t, expected.Line, actual.Line)
+				assert.Equal(t, expected.Col, actual.Col)
+				assert.Equal(t, expected.Severity, actual.Severity)
+				assert.Equal(t, expected.Rule, actual.Rule)
+				assert.NotEmpty(t, actual.Message)
+			}
+			assert.Equal(t, len(tt.expected) > 0 && tt.expected[0].Severity == check.SeverityError, check.HasErrors(diags))
+		})
+	}
+}
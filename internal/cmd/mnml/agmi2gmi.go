@@ -1,48 +1,206 @@
 package mnml
 
 import (
-	"fmt"
-	"os"
+	"io"
+	"path/filepath"
+	"strings"
 
+	"github.com/fhofherr/mnml/format"
 	"github.com/fhofherr/mnml/gemtext"
+	"github.com/fhofherr/mnml/internal/site"
 	"github.com/spf13/cobra"
 )
 
+type agmi2gmiFlags struct {
+	keepModelines    bool
+	normalizeParSep  bool
+	maxHeadingLevel  int
+	trailingNewlines string
+	rewriteLinks     []string
+	strict           bool
+	gemtextCompat    bool
+}
+
+func (f *agmi2gmiFlags) options() ([]gemtext.Option, error) {
+	var opts []gemtext.Option
+
+	if f.keepModelines {
+		opts = append(opts, gemtext.KeepModelines())
+	}
+	if f.normalizeParSep {
+		opts = append(opts, gemtext.NormalizeParagraphSeparators())
+	}
+	if f.maxHeadingLevel > 0 {
+		opts = append(opts, gemtext.MaxHeadingLevel(f.maxHeadingLevel))
+	}
+	switch f.trailingNewlines {
+	case "keep":
+	case "one":
+		opts = append(opts, gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesOne))
+	case "none":
+		opts = append(opts, gemtext.WithTrailingNewlines(gemtext.TrailingNewlinesNone))
+	default:
+		return nil, newUsageError("invalid value for --trailing-newlines: %s", f.trailingNewlines)
+	}
+	if len(f.rewriteLinks) > 0 {
+		rewrite, err := rewriteLinkPrefixes(f.rewriteLinks)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, gemtext.RewriteLinks(rewrite))
+	}
+	if f.strict {
+		opts = append(opts, gemtext.Strict())
+	}
+	if f.gemtextCompat {
+		opts = append(opts, gemtext.GemtextCompatible())
+	}
+	return opts, nil
+}
+
+// rewriteLinkPrefixes parses rules of the form FROM=TO and returns a function
+// replacing the prefix FROM of an URI with TO. The first matching rule wins.
+func rewriteLinkPrefixes(rules []string) (func(string) string, error) {
+	type rule struct{ from, to string }
+
+	parsed := make([]rule, 0, len(rules))
+	for _, r := range rules {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, newUsageError("invalid value for --rewrite-link: %s", r)
+		}
+		parsed = append(parsed, rule{from: parts[0], to: parts[1]})
+	}
+	return func(uri string) string {
+		for _, r := range parsed {
+			if strings.HasPrefix(uri, r.from) {
+				return r.to + strings.TrimPrefix(uri, r.from)
+			}
+		}
+		return uri
+	}, nil
+}
+
 func newAGMI2GMICmd() *cobra.Command {
-	var outFile string
+	var (
+		outFile  string
+		outDir   string
+		siteRoot string
+		flags    agmi2gmiFlags
+	)
 
 	agmi2gmi := &cobra.Command{
-		Use:   "agmi2gmi",
+		Use:   "agmi2gmi FILE...",
 		Short: "Transform Almost Gemtext to Gemtext",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			inFile := args[0] // The ExactArgs ensures this is always there.
+		Long: `Transform Almost Gemtext to Gemtext.
 
-			in, err := os.Open(inFile)
-			if err != nil {
-				return fmt.Errorf("open input: %v", err)
-			}
-			defer in.Close()
+A single FILE is written to stdout unless --output or --output-dir is
+given. Use - as FILE to read from stdin, e.g. to use agmi2gmi as a filter
+in an editor.
 
-			out := os.Stdout
-			if outFile != "" {
-				var err error
+If several files are given, each is converted next to itself or into
+--output-dir with its extension changed to .gmi. The files are converted
+concurrently. A failure to convert one file does not stop the conversion of
+the others.
 
-				out, err = os.Create(outFile)
-				if err != nil {
-					return fmt.Errorf("open output: %v", err)
-				}
-				defer out.Close()
+Include directives like <!-- include: partials/contact.agmi --> are
+replaced by the referenced file. Paths are relative to the including file.
+Paths starting with a slash are relative to --site-root, which defaults to
+the directory of FILE.`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := flags.options()
+			if err != nil {
+				return err
+			}
+			f, err := lookupFormat(gemtext.FormatName)
+			if err != nil {
+				return err
+			}
+			jobs, err := planAGMI2GMI(args, outFile, outDir, f)
+			if err != nil {
+				return err
+			}
+			for i := range jobs {
+				jobs[i].root = siteRoot
+				jobs[i].verbatim = flags.gemtextCompat
 			}
 
-			if err := gemtext.FromAlmostGemtext(in, out); err != nil {
-				return fmt.Errorf("convert %s to Gemtext: %v", inFile, err)
+			convert := func(j conversion) error {
+				return j.run(cmd, func(in io.Reader, out io.Writer) error {
+					return gemtext.FromAlmostGemtextWithOptions(in, out, opts...)
+				})
 			}
-			return nil
+			if len(jobs) == 1 {
+				return convert(jobs[0])
+			}
+
+			errs := runConcurrently(len(jobs), func(i int) error {
+				return convert(jobs[i])
+			})
+			return reportErrors(cmd, len(jobs), errs)
 		},
 	}
 	agmi2gmi.Flags().StringVarP(
 		&outFile, "output", "o", "", "Write the converted text to this file. Defaults to stdout if missing.")
+	agmi2gmi.Flags().StringVar(
+		&outDir, "output-dir", "", "Write the converted files to this directory.")
+	agmi2gmi.Flags().StringVar(
+		&siteRoot, "site-root", "", "Resolve include paths starting with a slash relative to this directory.")
+	agmi2gmi.Flags().BoolVar(
+		&flags.keepModelines, "keep-modelines", false, "Copy modelines to the output instead of dropping them.")
+	agmi2gmi.Flags().BoolVar(
+		&flags.normalizeParSep, "normalize-paragraphs", false, "Separate paragraphs by exactly one blank line.")
+	agmi2gmi.Flags().IntVar(
+		&flags.maxHeadingLevel, "max-heading-level", 0, "Reduce deeper headings to this level. Zero keeps all levels.")
+	agmi2gmi.Flags().StringVar(
+		&flags.trailingNewlines, "trailing-newlines", "keep",
+		"Newlines at the end of the output. One of keep, one, or none.")
+	agmi2gmi.Flags().StringArrayVar(
+		&flags.rewriteLinks, "rewrite-link", nil,
+		"Replace the prefix FROM of link URIs with TO. Format: FROM=TO. May be repeated.")
+	agmi2gmi.Flags().BoolVar(
+		&flags.strict, "strict", false, "Fail if the input violates the Almost Gemtext specification.")
+	agmi2gmi.Flags().BoolVar(
+		&flags.gemtextCompat, "gemtext-compatible", false,
+		"Treat the input as Gemtext and copy it unchanged. Only --rewrite-link and --trailing-newlines apply."+
+			" Include directives are not expanded.")
 
 	return agmi2gmi
 }
+
+// planAGMI2GMI decides where to write the conversion of each of inFiles to
+// the format f.
+func planAGMI2GMI(inFiles []string, outFile, outDir string, f format.Format) ([]conversion, error) {
+	if outFile != "" && outDir != "" {
+		return nil, newUsageError("--output and --output-dir are mutually exclusive")
+	}
+	if len(inFiles) == 1 && outDir == "" {
+		if outFile == "" {
+			outFile = stdio
+		}
+		return []conversion{{inFile: inFiles[0], outFile: outFile}}, nil
+	}
+	if outFile != "" {
+		return nil, newUsageError("--output requires exactly one input file")
+	}
+
+	jobs := make([]conversion, 0, len(inFiles))
+	seen := make(map[string]string, len(inFiles))
+	for _, inFile := range inFiles {
+		if inFile == stdio {
+			return nil, newUsageError("stdin can only be converted if it is the only input and --output-dir is not set")
+		}
+		out := site.OutputPath(inFile, f)
+		if outDir != "" {
+			out = filepath.Join(outDir, filepath.Base(out))
+		}
+		if other, ok := seen[out]; ok {
+			return nil, newUsageError("%s and %s would both be written to %s", other, inFile, out)
+		}
+		seen[out] = inFile
+		jobs = append(jobs, conversion{inFile: inFile, outFile: out})
+	}
+	return jobs, nil
+}
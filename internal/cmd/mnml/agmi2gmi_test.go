@@ -1,7 +1,11 @@
 package mnml_test
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/fhofherr/mnml/internal/cmd/mnml"
@@ -22,3 +26,277 @@ func TestAGMI2GMICmd(t *testing.T) {
 	assert.NoError(t, err)
 	assert.FileExists(t, destFile)
 }
+
+func TestAGMI2GMICmd_Options(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcFile := filepath.Join(tempDir, "index.agmi")
+	destFile := filepath.Join(tempDir, "index.gmi")
+	input := "<!-- vim: set tw=72: -->\n\n#### Title\n\n\n=> posts/first.agmi First post\n\n\n"
+	if !assert.NoError(t, os.WriteFile(srcFile, []byte(input), 0600)) {
+		return
+	}
+
+	cmd := mnml.New()
+	cmd.SetArgs([]string{
+		"agmi2gmi",
+		"--output", destFile,
+		"--keep-modelines",
+		"--normalize-paragraphs",
+		"--max-heading-level", "3",
+		"--trailing-newlines", "one",
+		"--rewrite-link", "posts/=/gemlog/",
+		"--strict",
+		srcFile,
+	})
+	err := cmd.Execute()
+	if !assert.NoError(t, err) {
+		return
+	}
+	actual, err := os.ReadFile(destFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "<!-- vim: set tw=72: -->\n\n### Title\n\n=> /gemlog/first.agmi First post\n", string(actual))
+}
+
+func TestAGMI2GMICmd_Stdin(t *testing.T) {
+	var out bytes.Buffer
+
+	cmd := mnml.New()
+	cmd.SetIn(strings.NewReader("<!-- vim: set tw=72: -->\n\nSome\ntext\n"))
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"agmi2gmi", "-"})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "Some text\n", out.String())
+}
+
+func TestAGMI2GMICmd_Batch(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     func(dir string) []string
+		expected []string
+	}{
+		{
+			name: "next to input",
+			args: func(dir string) []string {
+				return []string{filepath.Join(dir, "first.agmi"), filepath.Join(dir, "posts", "second.agmi")}
+			},
+			expected: []string{"first.gmi", filepath.Join("posts", "second.gmi")},
+		},
+		{
+			name: "output directory",
+			args: func(dir string) []string {
+				return []string{
+					"--output-dir", filepath.Join(dir, "out"),
+					filepath.Join(dir, "first.agmi"), filepath.Join(dir, "posts", "second.agmi"),
+				}
+			},
+			expected: []string{filepath.Join("out", "first.gmi"), filepath.Join("out", "second.gmi")},
+		},
+		{
+			name: "single file into output directory",
+			args: func(dir string) []string {
+				return []string{"--output-dir", filepath.Join(dir, "out"), filepath.Join(dir, "first.agmi")}
+			},
+			expected: []string{filepath.Join("out", "first.gmi")},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanUp := testsupport.MkdirTemp(t)
+			defer cleanUp()
+
+			testsupport.WriteFiles(t, tempDir, map[string]string{
+				"first.agmi":                          "# First\n",
+				filepath.Join("posts", "second.agmi"): "# Second\n",
+			})
+			if !assert.NoError(t, os.Mkdir(filepath.Join(tempDir, "out"), 0700)) {
+				return
+			}
+
+			cmd := mnml.New()
+			cmd.SetArgs(append([]string{"agmi2gmi"}, tt.args(tempDir)...))
+			err := cmd.Execute()
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, name := range tt.expected {
+				assert.FileExists(t, filepath.Join(tempDir, name))
+			}
+		})
+	}
+}
+
+func TestAGMI2GMICmd_BatchErrors(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	testsupport.WriteFiles(t, tempDir, map[string]string{
+		"valid.agmi":   "# Valid\n",
+		"invalid.agmi": "Some text\n* A list item\n",
+	})
+	validFile := filepath.Join(tempDir, "valid.agmi")
+	invalidFile := filepath.Join(tempDir, "invalid.agmi")
+	missingFile := filepath.Join(tempDir, "missing.agmi")
+
+	var stderr bytes.Buffer
+
+	cmd := mnml.New()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"agmi2gmi", "--strict", invalidFile, missingFile, validFile})
+	err := cmd.Execute()
+	assert.EqualError(t, err, "2 of 3 files failed")
+	assert.Equal(t, mnml.ExitFailure, mnml.ExitCode(err))
+
+	assert.Contains(t, stderr.String(), invalidFile+":2:1: list must be separated")
+	assert.Contains(t, stderr.String(), missingFile)
+	assert.FileExists(t, filepath.Join(tempDir, "valid.gmi"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "invalid.gmi"))
+}
+
+func TestAGMI2GMICmd_BatchUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{
+			name: "output with several inputs",
+			args: []string{"-o", "out.gmi", "a.agmi", "b.agmi"},
+			err:  "--output requires exactly one input file",
+		},
+		{
+			name: "output and output directory",
+			args: []string{"-o", "out.gmi", "--output-dir", "out", "a.agmi"},
+			err:  "--output and --output-dir are mutually exclusive",
+		},
+		{
+			name: "stdin with several inputs",
+			args: []string{"-", "a.agmi"},
+			err:  "stdin can only be converted if it is the only input and --output-dir is not set",
+		},
+		{
+			name: "same output for several inputs",
+			args: []string{"--output-dir", "out", filepath.Join("a", "post.agmi"), filepath.Join("b", "post.agmi")},
+			err: fmt.Sprintf("%s and %s would both be written to %s",
+				filepath.Join("a", "post.agmi"), filepath.Join("b", "post.agmi"), filepath.Join("out", "post.gmi")),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := mnml.New()
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(append([]string{"agmi2gmi"}, tt.args...))
+			err := cmd.Execute()
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, mnml.ExitUsage, mnml.ExitCode(err))
+		})
+	}
+}
+
+func TestAGMI2GMICmd_GemtextCompatible(t *testing.T) {
+	srcFile := filepath.Join(
+		testsupport.ProjectRoot(t), "gemtext", "testdata",
+		"TestFromAlmostGemtextWithOptions_GemtextConformance", "gemlog_post.gmi")
+	expected, err := os.ReadFile(srcFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var out bytes.Buffer
+
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"agmi2gmi", "--gemtext-compatible", srcFile})
+	err = cmd.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), out.String())
+}
+
+func TestAGMI2GMICmd_Includes(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	testsupport.WriteFiles(t, tempDir, map[string]string{
+		"blog/post.agmi":        "# Post\n\n<!-- include: /partials/contact.agmi -->\n",
+		"blog/broken.agmi":      "# Broken\n\n<!-- include: ../partials/broken.agmi -->\n",
+		"partials/contact.agmi": "=> mailto:me@example.com Mail\n",
+		"partials/broken.agmi":  "Text\n<!-- include: missing.agmi -->\n",
+		"blog/deep.agmi":        "<!-- include: /partials/footer.agmi -->\n\nText\n\n####### Too deep\n",
+		"blog/deep-footer.agmi": "# Post\n\n<!-- include: /partials/deep.agmi -->\n",
+		"partials/footer.agmi":  "Line 1\nLine 2\nLine 3\nLine 4\nLine 5\n",
+		"partials/deep.agmi":    "Text\n\n####### Too deep\n",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+		exitCode int
+	}{
+		{
+			name:     "site root",
+			args:     []string{"--site-root", tempDir, filepath.Join(tempDir, "blog", "post.agmi")},
+			expected: "# Post\n\n=> mailto:me@example.com Mail\n",
+		},
+		{
+			name: "missing file in included file",
+			args: []string{"--site-root", tempDir, filepath.Join(tempDir, "blog", "broken.agmi")},
+			err: filepath.Join(tempDir, "partials", "broken.agmi") +
+				":2:1: include partials/missing.agmi: file does not exist",
+			exitCode: mnml.ExitInvalidInput,
+		},
+		{
+			name:     "strict mode error after an include",
+			args:     []string{"--strict", "--site-root", tempDir, filepath.Join(tempDir, "blog", "deep.agmi")},
+			err:      filepath.Join(tempDir, "blog", "deep.agmi") + ":5:1: heading level 7 exceeds the maximum of 6",
+			exitCode: mnml.ExitInvalidInput,
+		},
+		{
+			name:     "strict mode error in an included file",
+			args:     []string{"--strict", "--site-root", tempDir, filepath.Join(tempDir, "blog", "deep-footer.agmi")},
+			err:      filepath.Join(tempDir, "partials", "deep.agmi") + ":3:1: heading level 7 exceeds the maximum of 6",
+			exitCode: mnml.ExitInvalidInput,
+		},
+		{
+			name:     "absolute include without site root",
+			args:     []string{filepath.Join(tempDir, "blog", "post.agmi")},
+			err:      filepath.Join(tempDir, "blog", "post.agmi") + ":3:1: include partials/contact.agmi: file does not exist",
+			exitCode: mnml.ExitInvalidInput,
+		},
+		{
+			name: "input outside of site root",
+			args: []string{"--site-root", filepath.Join(tempDir, "partials"), filepath.Join(tempDir, "blog", "post.agmi")},
+			err: fmt.Sprintf("%s is not within the site root %s",
+				filepath.Join(tempDir, "blog", "post.agmi"), filepath.Join(tempDir, "partials")),
+			exitCode: mnml.ExitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			cmd := mnml.New()
+			cmd.SetOut(&out)
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(append([]string{"agmi2gmi"}, tt.args...))
+			err := cmd.Execute()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.Equal(t, tt.exitCode, mnml.ExitCode(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
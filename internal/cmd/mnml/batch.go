@@ -0,0 +1,213 @@
+package mnml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/format"
+	"github.com/spf13/cobra"
+)
+
+// stdio is the file name standing for stdin or stdout.
+const stdio = "-"
+
+// conversion converts a single input file to an output file. Either may be
+// stdio.
+type conversion struct {
+	inFile  string
+	outFile string
+
+	// root is the directory absolute include paths are relative to.
+	// Defaults to the directory of inFile or the working directory if
+	// inFile is stdio.
+	root string
+
+	// verbatim disables the expansion of include directives.
+	verbatim bool
+}
+
+func (c conversion) run(cmd *cobra.Command, convert format.Converter) (err error) {
+	in := cmd.InOrStdin()
+	if c.inFile != stdio {
+		f, oerr := os.Open(c.inFile)
+		if oerr != nil {
+			return fmt.Errorf("open input: %w", oerr)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var sm *agmi.SourceMap
+	if !c.verbatim {
+		expanded, m, eerr := c.expandIncludes(in)
+		if eerr != nil {
+			return eerr
+		}
+		in, sm = expanded, m
+	}
+
+	out := cmd.OutOrStdout()
+	if c.outFile != stdio {
+		f, oerr := os.Create(c.outFile)
+		if oerr != nil {
+			return fmt.Errorf("open output: %w", oerr)
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("close output: %w", cerr)
+			}
+			if err != nil {
+				// Do not leave incomplete output behind.
+				_ = os.Remove(c.outFile)
+			}
+		}()
+		out = f
+	}
+
+	if err := convert(in, out); err != nil {
+		if sm != nil {
+			c.translate(sm, err)
+		}
+		return inputError(c.name(), err, fmt.Sprintf("convert %s", c.name()))
+	}
+	return nil
+}
+
+// expandIncludes returns the content of in with all include directives
+// expanded and its source map.
+func (c conversion) expandIncludes(in io.Reader) (io.Reader, *agmi.SourceMap, error) {
+	root := c.includeRoot()
+	name := ""
+	if c.inFile != stdio {
+		rel, err := relPath(root, c.inFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		name = filepath.ToSlash(rel)
+	}
+
+	var buf bytes.Buffer
+	sm, err := agmi.ExpandIncludesWithMap(&buf, in, os.DirFS(root), name)
+	if err != nil {
+		c.joinRoot(err)
+		return nil, nil, inputError(c.name(), err, fmt.Sprintf("expand includes of %s", c.name()))
+	}
+	return &buf, sm, nil
+}
+
+// translate moves the position of err from the expanded input to the file
+// it was read from.
+func (c conversion) translate(sm *agmi.SourceMap, err error) {
+	sm.Translate(err)
+	c.joinRoot(err)
+}
+
+// joinRoot makes the File of err relative to the working directory if err
+// is a SyntaxError within an included document.
+func (c conversion) joinRoot(err error) {
+	var serr *agmi.SyntaxError
+	if errors.As(err, &serr) && serr.File != "" {
+		serr.File = filepath.Join(c.includeRoot(), filepath.FromSlash(serr.File))
+	}
+}
+
+// includeRoot returns the directory absolute include paths are relative
+// to.
+func (c conversion) includeRoot() string {
+	root := c.root
+	if root == "" && c.inFile != stdio {
+		root = filepath.Dir(c.inFile)
+	}
+	if root == "" {
+		root = "."
+	}
+	return root
+}
+
+// name returns the name of the input file for use in messages.
+func (c conversion) name() string {
+	if c.inFile == stdio {
+		return "<stdin>"
+	}
+	return c.inFile
+}
+
+// relPath returns the path of file relative to root. It returns a
+// usageError if file is not within root.
+func relPath(root, file string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newUsageError("%s is not within the site root %s", file, root)
+	}
+	return rel, nil
+}
+
+// runConcurrently calls f for every i in [0, n) using up to one goroutine
+// per CPU. It returns the non-nil errors returned by f ordered by i.
+func runConcurrently(n int, f func(i int) error) []error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, n)
+		sem  = make(chan struct{}, runtime.NumCPU())
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := errs[:0]
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// reportErrors writes each of errs to the error output of cmd and returns a
+// batchError summarizing them. It returns nil if errs is empty.
+func reportErrors(cmd *cobra.Command, total int, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+	}
+	return &batchError{errs: errs, total: total}
+}
+
+// batchError summarizes the errors which occurred while processing several
+// files independently of each other.
+type batchError struct {
+	errs  []error
+	total int
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("%d of %d files failed", len(e.errs), e.total)
+}
@@ -0,0 +1,185 @@
+package mnml
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+
+	"github.com/fhofherr/mnml/format"
+	"github.com/fhofherr/mnml/internal/site"
+	"github.com/spf13/cobra"
+)
+
+func newBuildCmd() *cobra.Command {
+	var (
+		to       []string
+		tagFeeds bool
+		baseURL  string
+		author   string
+		drafts   bool
+		future   bool
+		force    bool
+		jobs     int
+		clean    bool
+		dryRun   bool
+		diff     bool
+	)
+
+	build := &cobra.Command{
+		Use:   "build SOURCE_DIR OUTPUT_DIR",
+		Short: "Build a site from a directory of Almost Gemtext documents",
+		Long: `Build a site from a directory of Almost Gemtext documents.
+
+For every format a separate directory named after the format is created
+within OUTPUT_DIR. All Almost Gemtext documents in SOURCE_DIR are converted
+to the format. All other files are copied verbatim.
+
+Documents may start with front matter enclosed in lines of three dashes:
+
+    ---
+    title: My first post
+    date: 2021-05-01
+    tags: gemini, mnml
+    ---
+
+If any document is tagged, an overview of all tags is written to
+tags/index and an index page listing the tagged documents, newest first,
+to tags/TAG. Pass --tag-feeds and --base-url to create an Atom feed for each
+tag as well. Tags are not case sensitive. Within file names, characters
+other than letters, digits, dashes, and underscores are replaced by dashes,
+and the tag index becomes _index. Tags ending up with the same file name
+are an error.
+
+Documents marked with draft: true or dated in the future are skipped
+unless --drafts or --future is passed. A summary listing all skipped
+documents is printed once the site was built.
+
+Documents and files which did not change since the previous build are
+neither converted nor copied again. Output files with unchanged content
+keep their modification time. The sources of the previous build are
+recorded in OUTPUT_DIR/` + site.CacheFile + `. Pass --force to convert all
+documents regardless.
+
+Up to --jobs documents are converted concurrently. The build stops on the
+first error or if it is interrupted.
+
+Files created by a previous build which no source produces any more, e.g.
+because a document was renamed, are removed. Other files within the
+directories of the formats are only removed if --clean is passed.
+
+Pass --dry-run to list the files which would be created, changed, or
+removed without writing anything. Add --diff to show a unified diff of
+every change as well, e.g. to review the effect of a new version of mnml
+on the whole site.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tagFeeds && baseURL == "" {
+				return newUsageError("--tag-feeds requires --base-url")
+			}
+			if jobs < 1 {
+				return newUsageError("--jobs must be at least 1")
+			}
+			b := site.Builder{
+				SourceDir: args[0],
+				OutputDir: args[1],
+				TagFeeds:  tagFeeds,
+				BaseURL:   baseURL,
+				Author:    author,
+				Drafts:    drafts,
+				Future:    future,
+				Force:     force,
+				Jobs:      jobs,
+				Clean:     clean,
+				DryRun:    dryRun,
+				Diff:      diff,
+			}
+			if len(to) == 0 {
+				b.Formats = format.All()
+			}
+			for _, name := range to {
+				f, err := lookupFormat(name)
+				if err != nil {
+					return err
+				}
+				b.Formats = append(b.Formats, f)
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			sum, err := b.BuildContext(ctx)
+			if err != nil {
+				return err
+			}
+			printSummary(cmd.OutOrStdout(), sum, dryRun)
+			if diff {
+				for _, c := range sum.Changes {
+					fmt.Fprint(cmd.OutOrStdout(), c.Diff)
+				}
+			}
+			return nil
+		},
+	}
+	build.Flags().StringSliceVarP(
+		&to, "to", "t", nil,
+		fmt.Sprintf("Formats to build. Any of: %s. Defaults to all formats.", strings.Join(format.Names(), ", ")))
+	build.Flags().BoolVar(
+		&tagFeeds, "tag-feeds", false, "Create an Atom feed for each tag.")
+	build.Flags().StringVar(
+		&baseURL, "base-url", "", "URL the site is published at, e.g. gemini://example.com/. Required by --tag-feeds.")
+	build.Flags().StringVar(
+		&author, "author", "", "Author named in feeds. Defaults to the host of --base-url.")
+	build.Flags().BoolVar(
+		&drafts, "drafts", false, "Include documents marked as draft.")
+	build.Flags().BoolVar(
+		&future, "future", false, "Include documents dated in the future.")
+	build.Flags().BoolVar(
+		&force, "force", false, "Convert all documents even if they did not change since the previous build.")
+	build.Flags().IntVarP(
+		&jobs, "jobs", "j", runtime.GOMAXPROCS(0), "Maximum number of documents to convert concurrently.")
+	build.Flags().BoolVar(
+		&clean, "clean", false, "Remove all files from the directories of the formats not created by this build.")
+	build.Flags().BoolVar(
+		&dryRun, "dry-run", false, "Neither write nor remove any files, but list the files which would change.")
+	build.Flags().BoolVar(
+		&diff, "diff", false, "Show a unified diff of every file changed by the build.")
+
+	return build
+}
+
+// printSummary writes sum to w. dryRun states that sum describes a dry run.
+func printSummary(w io.Writer, sum *site.Summary, dryRun bool) {
+	if dryRun {
+		fmt.Fprint(w, "Dry run: ")
+	}
+	fmt.Fprintf(w, "Converted %s, copied %s, skipped %s, left %s unchanged, removed %s.\n",
+		plural(sum.Documents, "document"), plural(sum.Files, "file"), plural(len(sum.Skipped), "document"),
+		plural(sum.Unchanged, "file"), plural(len(sum.Removed), "file"))
+	for _, s := range sum.Skipped {
+		fmt.Fprintf(w, "Skipped %s: %s\n", s.Path, s.Reason)
+	}
+	if !dryRun {
+		for _, r := range sum.Removed {
+			fmt.Fprintf(w, "Removed %s\n", r)
+		}
+		return
+	}
+	verbs := map[site.ChangeOp]string{
+		site.ChangeCreate: "Would create",
+		site.ChangeModify: "Would change",
+		site.ChangeRemove: "Would remove",
+	}
+	for _, c := range sum.Changes {
+		fmt.Fprintf(w, "%s %s\n", verbs[c.Op], c.Path)
+	}
+}
+
+// plural returns n followed by noun, which is pluralized unless n is one.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,115 @@
+package mnml_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/cmd/mnml"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCmd(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(testsupport.ProjectRoot(t), "docs")
+
+	var out bytes.Buffer
+
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"build", "--to", "gemtext", srcDir, tempDir})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tempDir, "gemtext", "almost_gemtext.gmi"))
+	assert.Equal(t, "Converted 1 document, copied 0 files, skipped 0 documents, left 0 files unchanged, removed 0 files.\n", out.String())
+}
+
+func TestBuildCmd_TagFeedsWithoutBaseURL(t *testing.T) {
+	cmd := mnml.New()
+	cmd.SetArgs([]string{"build", "--tag-feeds", "src", "out"})
+	err := cmd.Execute()
+	assert.EqualError(t, err, "--tag-feeds requires --base-url")
+	assert.Equal(t, mnml.ExitUsage, mnml.ExitCode(err))
+}
+
+func TestBuildCmd_InvalidJobs(t *testing.T) {
+	cmd := mnml.New()
+	cmd.SetArgs([]string{"build", "--jobs", "0", "src", "out"})
+	err := cmd.Execute()
+	assert.EqualError(t, err, "--jobs must be at least 1")
+	assert.Equal(t, mnml.ExitUsage, mnml.ExitCode(err))
+}
+
+func TestBuildCmd_DryRun(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(testsupport.ProjectRoot(t), "docs")
+	stale := filepath.Join(tempDir, "gemtext", "stale.gmi")
+	if !assert.NoError(t, os.MkdirAll(filepath.Dir(stale), 0700)) {
+		return
+	}
+	if !assert.NoError(t, os.WriteFile(stale, []byte("# Stale\n"), 0600)) {
+		return
+	}
+
+	var out bytes.Buffer
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"build", "--to", "gemtext", "--clean", "--dry-run", srcDir, tempDir})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "Dry run: Converted 1 document, copied 0 files, skipped 0 documents, left 0 files unchanged, removed 1 file.\n"+
+		"Would create gemtext/almost_gemtext.gmi\n"+
+		"Would remove gemtext/stale.gmi\n", out.String())
+	assert.FileExists(t, stale)
+	assert.NoFileExists(t, filepath.Join(tempDir, "gemtext", "almost_gemtext.gmi"))
+}
+
+func TestBuildCmd_DryRunDiff(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	outDir := filepath.Join(tempDir, "out")
+	if !assert.NoError(t, os.Mkdir(srcDir, 0700)) {
+		return
+	}
+	srcFile := filepath.Join(srcDir, "index.agmi")
+	if !assert.NoError(t, os.WriteFile(srcFile, []byte("# Home\n\nFirst\nversion.\n"), 0600)) {
+		return
+	}
+	cmd := mnml.New()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"build", "--to", "gemtext", srcDir, outDir})
+	if !assert.NoError(t, cmd.Execute()) {
+		return
+	}
+
+	if !assert.NoError(t, os.WriteFile(srcFile, []byte("# Home\n\nSecond\nversion.\n"), 0600)) {
+		return
+	}
+	var out bytes.Buffer
+	cmd = mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"build", "--to", "gemtext", "--dry-run", "--diff", srcDir, outDir})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, "Dry run: Converted 1 document, copied 0 files, skipped 0 documents, left 0 files unchanged, removed 0 files.\n"+
+		"Would change gemtext/index.gmi\n"+
+		"--- a/gemtext/index.gmi\n"+
+		"+++ b/gemtext/index.gmi\n"+
+		"@@ -1,3 +1,3 @@\n"+
+		" # Home\n"+
+		" \n"+
+		"-First version.\n"+
+		"+Second version.\n", out.String())
+
+	actual, err := os.ReadFile(filepath.Join(outDir, "gemtext", "index.gmi"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "# Home\n\nFirst version.\n", string(actual))
+	}
+}
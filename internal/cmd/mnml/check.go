@@ -0,0 +1,75 @@
+package mnml
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fhofherr/mnml/internal/check"
+	"github.com/spf13/cobra"
+)
+
+func newCheckCmd() *cobra.Command {
+	var format string
+
+	checkCmd := &cobra.Command{
+		Use:   "check FILE...",
+		Short: "Check Almost Gemtext documents for problems",
+		Long: `Check Almost Gemtext documents for problems.
+
+Every problem found is reported as file:line:column: severity: message.
+The command fails if at least one problem has the severity error.`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return newUsageError("unsupported format: %s", format)
+			}
+
+			diags := []check.Diagnostic{} // Render as [] instead of null in JSON.
+			for _, inFile := range args {
+				ds, err := checkFile(inFile)
+				if err != nil {
+					return err
+				}
+				diags = append(diags, ds...)
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(diags); err != nil {
+					return fmt.Errorf("write diagnostics: %w", err)
+				}
+			} else {
+				for _, d := range diags {
+					fmt.Fprintln(out, d)
+				}
+			}
+
+			if check.HasErrors(diags) {
+				return errProblemsFound
+			}
+			return nil
+		},
+	}
+	checkCmd.Flags().StringVarP(
+		&format, "format", "f", "text", "Output format of the diagnostics. Either text or json.")
+
+	return checkCmd
+}
+
+func checkFile(inFile string) ([]check.Diagnostic, error) {
+	in, err := os.Open(inFile)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	diags, err := check.Document(inFile, in)
+	if err != nil {
+		return nil, inputError(inFile, err, fmt.Sprintf("check %s", inFile))
+	}
+	return diags, nil
+}
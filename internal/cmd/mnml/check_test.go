@@ -0,0 +1,68 @@
+package mnml_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/check"
+	"github.com/fhofherr/mnml/internal/cmd/mnml"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCmd(t *testing.T) {
+	srcFile := filepath.Join(testsupport.ProjectRoot(t), "docs", "almost_gemtext.agmi")
+
+	var out bytes.Buffer
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"check", srcFile})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestCheckCmd_JSON(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcFile := filepath.Join(tempDir, "broken.agmi")
+	err := os.WriteFile(srcFile, []byte("Some text\n* A list item\n\n```\n"), 0600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var out bytes.Buffer
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"check", "--format", "json", srcFile})
+	err = cmd.Execute()
+	assert.Error(t, err)
+
+	var diags []check.Diagnostic
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &diags)) {
+		return
+	}
+	assert.Equal(t, []check.Diagnostic{
+		{
+			File:     srcFile,
+			Line:     2,
+			Col:      1,
+			Severity: check.SeverityError,
+			Rule:     check.RuleListParagraph,
+			Message:  "list must be separated from the preceding paragraph by an empty line",
+		},
+		{
+			File:     srcFile,
+			Line:     4,
+			Col:      1,
+			Severity: check.SeverityError,
+			Rule:     check.RuleUnclosedPreFmt,
+			Message:  "pre-formatted text is never closed",
+		},
+	}, diags)
+}
@@ -0,0 +1,57 @@
+package mnml
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fhofherr/mnml/format"
+	"github.com/spf13/cobra"
+
+	// Register the formats shipped with mnml.
+	_ "github.com/fhofherr/mnml/gemtext"
+)
+
+func newConvertCmd() *cobra.Command {
+	var (
+		outFile  string
+		to       string
+		siteRoot string
+	)
+
+	convert := &cobra.Command{
+		Use:   "convert FILE",
+		Short: "Transform Almost Gemtext to another format",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inFile := args[0] // The ExactArgs ensures this is always there.
+
+			f, err := lookupFormat(to)
+			if err != nil {
+				return err
+			}
+
+			if outFile == "" {
+				outFile = stdio
+			}
+			return conversion{inFile: inFile, outFile: outFile, root: siteRoot}.run(cmd, f.Convert)
+		},
+	}
+	convert.Flags().StringVarP(
+		&outFile, "output", "o", "", "Write the converted text to this file. Defaults to stdout if missing.")
+	convert.Flags().StringVarP(
+		&to, "to", "t", "", fmt.Sprintf("Format to convert to. One of: %s.", strings.Join(format.Names(), ", ")))
+	convert.Flags().StringVar(
+		&siteRoot, "site-root", "", "Resolve include paths starting with a slash relative to this directory.")
+	_ = convert.MarkFlagRequired("to")
+
+	return convert
+}
+
+func lookupFormat(name string) (format.Format, error) {
+	f, ok := format.Lookup(name)
+	if !ok {
+		return format.Format{}, newUsageError(
+			"unknown format: %s: available formats: %s", name, strings.Join(format.Names(), ", "))
+	}
+	return f, nil
+}
@@ -0,0 +1,36 @@
+package mnml_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/cmd/mnml"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertCmd(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcFile := filepath.Join(testsupport.ProjectRoot(t), "docs", "almost_gemtext.agmi")
+	destFile := filepath.Join(tempDir, "almost_gemtext.gmi")
+
+	cmd := mnml.New()
+	cmd.SetArgs([]string{"convert", "--to", "gemtext", "--output", destFile, srcFile})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.FileExists(t, destFile)
+}
+
+func TestConvertCmd_UnknownFormat(t *testing.T) {
+	srcFile := filepath.Join(testsupport.ProjectRoot(t), "docs", "almost_gemtext.agmi")
+
+	cmd := mnml.New()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"convert", "--to", "no-such-format", srcFile})
+	err := cmd.Execute()
+	assert.EqualError(t, err, "unknown format: no-such-format: available formats: gemtext")
+}
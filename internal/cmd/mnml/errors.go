@@ -0,0 +1,108 @@
+package mnml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of mnml.
+const (
+	ExitOK           = 0 // Success.
+	ExitFailure      = 1 // Any error not covered by a more specific exit code.
+	ExitUsage        = 2 // Invalid arguments or flags.
+	ExitInvalidInput = 3 // Input contains errors.
+	ExitIO           = 4 // Reading or writing a file failed.
+)
+
+// errProblemsFound is returned by commands which found errors in their
+// input and reported them already.
+var errProblemsFound = errors.New("errors found")
+
+// ExitCode returns the exit code for the error returned by the command
+// created by New.
+func ExitCode(err error) int {
+	var (
+		berr  *batchError
+		uerr  *usageError
+		serr  *agmi.SyntaxError
+		pserr *fs.PathError
+	)
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &berr):
+		// Use the exit code of the individual errors if they agree.
+		code := ExitCode(berr.errs[0])
+		for _, err := range berr.errs[1:] {
+			if ExitCode(err) != code {
+				return ExitFailure
+			}
+		}
+		return code
+	case errors.As(err, &uerr):
+		return ExitUsage
+	case errors.As(err, &serr), errors.Is(err, errProblemsFound):
+		return ExitInvalidInput
+	case errors.As(err, &pserr):
+		return ExitIO
+	default:
+		return ExitFailure
+	}
+}
+
+// usageError marks errors caused by invalid arguments or flags.
+type usageError struct {
+	err error
+}
+
+func newUsageError(format string, args ...interface{}) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// checkUsage makes sure errors caused by invalid flags or arguments of cmd
+// and all its sub-commands are returned as usageErrors.
+func checkUsage(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, c := range cmd.Commands() {
+		checkUsage(c)
+	}
+}
+
+// inputError describes err which occurred while processing the file name.
+//
+// Syntax errors are reported as name:line:col: message, or as
+// file:line:col: message if they occurred in another file. All other errors
+// are prefixed with msg.
+func inputError(name string, err error, msg string) error {
+	var serr *agmi.SyntaxError
+	if errors.As(err, &serr) {
+		if serr.File != "" {
+			return serr
+		}
+		return fmt.Errorf("%s:%w", name, serr)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
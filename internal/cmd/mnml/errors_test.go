@@ -0,0 +1,99 @@
+package mnml_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/cmd/mnml"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	files := map[string]string{
+		"valid.agmi":   "# Title\n",
+		"invalid.agmi": "Some text\n* A list item\n",
+		"empty.agmi":   "",
+	}
+	for name, content := range files {
+		if !assert.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0600)) {
+			return
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(tempDir, name)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		err      string
+	}{
+		{
+			name:     "success",
+			args:     []string{"agmi2gmi", "-o", path("valid.gmi"), path("valid.agmi")},
+			exitCode: mnml.ExitOK,
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"agmi2gmi", "--no-such-flag", path("valid.agmi")},
+			exitCode: mnml.ExitUsage,
+			err:      "unknown flag: --no-such-flag",
+		},
+		{
+			name:     "missing argument",
+			args:     []string{"agmi2gmi"},
+			exitCode: mnml.ExitUsage,
+			err:      "requires at least 1 arg(s), only received 0",
+		},
+		{
+			name:     "invalid flag value",
+			args:     []string{"convert", "--to", "no-such-format", path("valid.agmi")},
+			exitCode: mnml.ExitUsage,
+			err:      "unknown format: no-such-format: available formats: gemtext",
+		},
+		{
+			name:     "syntax error",
+			args:     []string{"agmi2gmi", "--strict", "-o", path("invalid.gmi"), path("invalid.agmi")},
+			exitCode: mnml.ExitInvalidInput,
+			err:      path("invalid.agmi") + ":2:1: list must be separated from the preceding paragraph by an empty line",
+		},
+		{
+			name:     "empty input",
+			args:     []string{"convert", "--to", "gemtext", "-o", path("empty.gmi"), path("empty.agmi")},
+			exitCode: mnml.ExitOK,
+		},
+		{
+			name:     "problems found by check",
+			args:     []string{"check", path("invalid.agmi")},
+			exitCode: mnml.ExitInvalidInput,
+			err:      "errors found",
+		},
+		{
+			name:     "missing input",
+			args:     []string{"agmi2gmi", path("missing.agmi")},
+			exitCode: mnml.ExitIO,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := mnml.New()
+			cmd.SetOut(&bytes.Buffer{})
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			assert.Equal(t, tt.exitCode, mnml.ExitCode(err), "error: %v", err)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package mnml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fhofherr/mnml/internal/linkcheck"
+	"github.com/spf13/cobra"
+)
+
+func newLinkCheckCmd() *cobra.Command {
+	var (
+		probe   bool
+		timeout time.Duration
+	)
+
+	linkCheckCmd := &cobra.Command{
+		Use:   "linkcheck SITE_DIR",
+		Short: "Check the links of a generated Gemini site",
+		Long: `Check the links of a generated Gemini site.
+
+SITE_DIR is the directory created for the gemtext format by mnml build,
+e.g. OUTPUT_DIR/gemtext. Every relative link within the Gemtext documents
+of SITE_DIR must point to an existing file. Links starting with a slash
+are relative to SITE_DIR. A link to a directory points to its index.gmi.
+
+Pass --probe to request absolute gemini:// and gopher:// URLs from their
+servers as well.
+
+Every broken link is reported as file:line: url: reason. The command
+fails if at least one link is broken.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			siteDir := args[0]
+			fi, err := os.Stat(siteDir)
+			if err != nil {
+				return fmt.Errorf("open site: %w", err)
+			}
+			if !fi.IsDir() {
+				return newUsageError("not a directory: %s", siteDir)
+			}
+
+			c := linkcheck.Checker{
+				Site:    os.DirFS(siteDir),
+				Probe:   probe,
+				Timeout: timeout,
+			}
+			problems, err := c.Check()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for _, p := range problems {
+				p.File = filepath.Join(siteDir, filepath.FromSlash(p.File))
+				fmt.Fprintln(out, p)
+			}
+			if len(problems) > 0 {
+				return errProblemsFound
+			}
+			return nil
+		},
+	}
+	linkCheckCmd.Flags().BoolVar(
+		&probe, "probe", false, "Request absolute gemini:// and gopher:// URLs from their servers.")
+	linkCheckCmd.Flags().DurationVar(
+		&timeout, "timeout", linkcheck.DefaultTimeout, "Time to wait for a server if --probe is passed.")
+
+	return linkCheckCmd
+}
@@ -0,0 +1,51 @@
+package mnml_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhofherr/mnml/internal/cmd/mnml"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkCheckCmd(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(testsupport.ProjectRoot(t), "docs")
+	cmd := mnml.New()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"build", "--to", "gemtext", srcDir, tempDir})
+	if !assert.NoError(t, cmd.Execute()) {
+		return
+	}
+
+	var out bytes.Buffer
+	cmd = mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"linkcheck", filepath.Join(tempDir, "gemtext")})
+	assert.NoError(t, cmd.Execute())
+	assert.Empty(t, out.String())
+}
+
+func TestLinkCheckCmd_BrokenLinks(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	err := os.WriteFile(filepath.Join(tempDir, "index.gmi"), []byte("# Home\n\n=> missing.gmi Missing\n"), 0600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var out bytes.Buffer
+	cmd := mnml.New()
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"linkcheck", tempDir})
+	err = cmd.Execute()
+	assert.Equal(t, mnml.ExitInvalidInput, mnml.ExitCode(err))
+	assert.Equal(t, filepath.Join(tempDir, "index.gmi")+":3: missing.gmi: target does not exist\n", out.String())
+}
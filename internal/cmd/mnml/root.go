@@ -7,9 +7,17 @@ func New() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "mnml",
 		Short: "A minimalistic Gemini and Gopher site generator.",
+
+		// Errors are printed by the caller of Execute. See ExitCode.
+		SilenceErrors: true,
 	}
 	rootCmd.AddCommand(newAGMI2GMICmd())
+	rootCmd.AddCommand(newBuildCmd())
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newConvertCmd())
+	rootCmd.AddCommand(newLinkCheckCmd())
 	rootCmd.AddCommand(newVersionCmd())
+	checkUsage(rootCmd)
 
 	return rootCmd
 }
@@ -0,0 +1,269 @@
+// Package linkcheck finds links within a generated site whose targets do
+// not exist.
+package linkcheck
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexFile is the file a Gemini server serves for a directory.
+const IndexFile = "index.gmi"
+
+// DefaultTimeout is the default time a Checker waits for a remote server.
+const DefaultTimeout = 10 * time.Second
+
+// Problem describes a link whose target does not exist.
+type Problem struct {
+	File   string // Path of the file containing the link, separated by slashes.
+	Line   int    // Line of the link, starting at 1.
+	URL    string // Target of the link as written in the file.
+	Reason string // Why the target is not reachable.
+}
+
+// String formats the Problem as file:line: url: reason.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.URL, p.Reason)
+}
+
+// Checker checks the links of all Gemtext documents within a site.
+//
+// Relative links are resolved against the location of the document
+// containing them. Links starting with a slash are relative to the root of
+// the site. A link to a directory refers to the IndexFile within the
+// directory. Query and fragment of a link are ignored.
+//
+// Links to absolute URLs are only checked if Probe is set. In this case the
+// Checker requests gemini:// and gopher:// URLs from their servers. Gemini
+// servers responding with a temporary or permanent failure (4x or 5x)
+// cause a Problem. Links with any other scheme are never checked.
+type Checker struct {
+	Site    fs.FS         // Root of the site.
+	Probe   bool          // Request absolute gemini:// and gopher:// URLs.
+	Timeout time.Duration // Time to wait for a remote server. Defaults to DefaultTimeout.
+}
+
+// Check checks all links of the site and returns the Problems found, sorted
+// by file and line.
+func (c *Checker) Check() ([]Problem, error) {
+	const op = "linkcheck/Checker.Check"
+
+	var problems []Problem
+	err := fs.WalkDir(c.Site, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".gmi" {
+			return nil
+		}
+		ps, err := c.checkFile(p)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, ps...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+func (c *Checker) checkFile(name string) ([]Problem, error) {
+	f, err := c.Site.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ls, err := links(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	var problems []Problem
+	for _, l := range ls {
+		if reason := c.checkLink(name, l.url); reason != "" {
+			problems = append(problems, Problem{File: name, Line: l.line, URL: l.url, Reason: reason})
+		}
+	}
+	return problems, nil
+}
+
+// checkLink returns why the link to target within the document name is
+// broken. It returns the empty string if the link is fine.
+func (c *Checker) checkLink(name, target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "invalid URL"
+	}
+	if u.Scheme != "" || u.Host != "" {
+		if !c.Probe {
+			return ""
+		}
+		if err := c.probe(u); err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+	if u.Path == "" {
+		// Refers to the document itself.
+		return ""
+	}
+
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(path.Dir(name), p)
+	}
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if p == "" {
+		p = "."
+	}
+	if !fs.ValidPath(p) {
+		return "outside of the site"
+	}
+	fi, err := fs.Stat(c.Site, p)
+	if err == nil && fi.IsDir() {
+		_, err = fs.Stat(c.Site, path.Join(p, IndexFile))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return "target does not exist"
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// probe requests u from its server.
+func (c *Checker) probe(u *url.URL) error {
+	switch u.Scheme {
+	case "gemini":
+		return c.probeGemini(u)
+	case "gopher":
+		return c.probeGopher(u)
+	default:
+		return nil
+	}
+}
+
+func (c *Checker) probeGemini(u *url.URL) error {
+	dialer := &net.Dialer{Timeout: c.timeout()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort(u, "1965"), &tls.Config{
+		// Gemini servers usually use self-signed certificates. Clients
+		// trust them on first use.
+		InsecureSkipVerify: true, // nolint: gosec
+		MinVersion:         tls.VersionTLS12,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	header, err := request(conn, u.String(), c.timeout())
+	if err != nil {
+		return err
+	}
+	// Status codes 2x and 3x indicate success and redirects. Resources
+	// requiring input (1x) or a client certificate (6x) exist as well.
+	if len(header) < 2 || !strings.ContainsRune("1236", rune(header[0])) {
+		return fmt.Errorf("server responded %q", header)
+	}
+	return nil
+}
+
+func (c *Checker) probeGopher(u *url.URL) error {
+	conn, err := net.DialTimeout("tcp", hostPort(u, "70"), c.timeout())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The path of a gopher URL consists of the item type followed by the
+	// selector.
+	itemType, selector := byte('1'), ""
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		itemType, selector = p[0], p[1:]
+	}
+	line, err := request(conn, selector, c.timeout())
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// Menus report errors with an item of type 3.
+	if itemType == '1' && (line == "" || line[0] == '3') {
+		return fmt.Errorf("server responded %q", line)
+	}
+	return nil
+}
+
+func (c *Checker) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+// request sends req to conn and returns the first line of the response.
+func request(conn net.Conn, req string, timeout time.Duration) (string, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// link is a link within a Gemtext document.
+type link struct {
+	line int
+	url  string
+}
+
+// links returns all links of the Gemtext document read from r. Lines within
+// pre-formatted text are never links.
+func links(r io.Reader) ([]link, error) {
+	var (
+		ls     []link
+		preFmt bool
+	)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "```"):
+			preFmt = !preFmt
+		case !preFmt && strings.HasPrefix(line, "=>"):
+			if fields := strings.Fields(line[2:]); len(fields) > 0 {
+				ls = append(ls, link{line: n, url: fields[0]})
+			}
+		}
+	}
+	return ls, sc.Err()
+}
@@ -0,0 +1,135 @@
+package linkcheck_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/fhofherr/mnml/internal/linkcheck"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Check(t *testing.T) {
+	site := fstest.MapFS{
+		"index.gmi": {Data: []byte("# Home\n\n" +
+			"=> posts/first.gmi First\n" +
+			"=> posts/missing.gmi Missing\n" +
+			"=> /posts/ Posts\n" +
+			"=> tags/ Tags\n" +
+			"=> image.png?size=large Image\n" +
+			"=> #top Top\n" +
+			"=> gemini://example.com External\n" +
+			"=> ../outside.gmi Outside\n\n" +
+			"```\n=> not/a/link.gmi\n```\n")},
+		"image.png":        {Data: []byte("not an image")},
+		"posts/index.gmi":  {Data: []byte("=> first.gmi First\n=> /index.gmi Home\n")},
+		"posts/first.gmi":  {Data: []byte("=> ../index.gmi Home\n=> second.gmi Second\n")},
+		"tags/gemini.gmi":  {Data: []byte("=> ../posts/first.gmi First\n")},
+		"posts/notes.text": {Data: []byte("=> missing.gmi Not Gemtext\n")},
+	}
+
+	c := linkcheck.Checker{Site: site}
+	problems, err := c.Check()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []linkcheck.Problem{
+		{File: "index.gmi", Line: 4, URL: "posts/missing.gmi", Reason: "target does not exist"},
+		{File: "index.gmi", Line: 6, URL: "tags/", Reason: "target does not exist"},
+		{File: "index.gmi", Line: 10, URL: "../outside.gmi", Reason: "outside of the site"},
+		{File: "posts/first.gmi", Line: 2, URL: "second.gmi", Reason: "target does not exist"},
+	}, problems)
+}
+
+func TestChecker_Check_Probe(t *testing.T) {
+	gemini := serveGemini(t, func(req string) string {
+		switch {
+		case strings.HasSuffix(req, "/missing.gmi"):
+			return "51 Not found"
+		case strings.HasSuffix(req, "/search"):
+			return "10 Search for"
+		case strings.HasSuffix(req, "/private.gmi"):
+			return "60 Client certificate required"
+		}
+		return "20 text/gemini"
+	})
+	gopher := serveGopher(t, func(selector string) string {
+		if selector == "/missing" {
+			return "3Not found\t\terror.host\t1"
+		}
+		return "iWelcome\t\terror.host\t1"
+	})
+
+	site := fstest.MapFS{
+		"index.gmi": {Data: []byte(fmt.Sprintf(
+			"=> gemini://%[1]s/\n=> gemini://%[1]s/missing.gmi\n=> gopher://%[2]s/1/\n=> gopher://%[2]s/1/missing\n"+
+				"=> gemini://%[1]s/search\n=> gemini://%[1]s/private.gmi\n",
+			gemini, gopher))},
+	}
+	c := linkcheck.Checker{Site: site, Probe: true, Timeout: 5 * time.Second}
+	problems, err := c.Check()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []linkcheck.Problem{
+		{File: "index.gmi", Line: 2, URL: fmt.Sprintf("gemini://%s/missing.gmi", gemini), Reason: `server responded "51 Not found"`},
+		{File: "index.gmi", Line: 4, URL: fmt.Sprintf("gopher://%s/1/missing", gopher), Reason: `server responded "3Not found\t\terror.host\t1"`},
+	}, problems)
+}
+
+// serveGemini starts a Gemini server answering each request with the
+// header returned by respond. It returns the address of the server.
+func serveGemini(t *testing.T, respond func(req string) string) string {
+	// Borrow the self-signed certificate of httptest.
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	cert := srv.TLS.Certificates[0]
+	srv.Close()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, l, respond)
+}
+
+// serveGopher starts a Gopher server answering each request with the line
+// returned by respond. It returns the address of the server.
+func serveGopher(t *testing.T, respond func(selector string) string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, l, respond)
+}
+
+func serve(t *testing.T, l net.Listener, respond func(req string) string) string {
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(conn, respond(strings.TrimRight(req, "\r\n"))+"\r\n")
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
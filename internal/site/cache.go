@@ -0,0 +1,169 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/fhofherr/mnml/internal/version"
+)
+
+// CacheFile is the name of the cache manifest within the output directory.
+const CacheFile = ".mnml-cache.json"
+
+// formatVersion is the version of the files created by a Builder.
+// Increment it whenever a change of mnml changes the files created from the
+// same sources.
+const formatVersion = 1
+
+// cacheVersion identifies the build of mnml creating the files within the
+// output directory.
+var cacheVersion = buildVersion()
+
+// buildVersion returns the version of this build of mnml. version.Version
+// and version.Commit are only set for release builds. It therefore includes
+// the module version and the VCS revision recorded by the go command as
+// well.
+func buildVersion() string {
+	v := fmt.Sprintf("%d-%s-%s", formatVersion, version.Version, version.Commit)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	v += "-" + info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			v += "-" + s.Value
+		}
+	}
+	return v
+}
+
+// manifest records the sources each file within the output directory was
+// created from. A Builder uses it to skip files whose sources did not change
+// since the previous build.
+type manifest struct {
+	Version string                `json:"version"` // Version of mnml which created the files.
+	Config  string                `json:"config"`  // Hash of the configuration affecting the files.
+	Entries map[string]cacheEntry `json:"entries"` // Entries by source path, separated by slashes.
+	Outputs []string              `json:"outputs"` // Files created within the output directory, separated by slashes.
+}
+
+// cacheEntry describes the sources of the files created from a single file
+// of the source directory.
+type cacheEntry struct {
+	Hash string            `json:"hash"`           // Hash of the source file.
+	Deps map[string]string `json:"deps,omitempty"` // Hashes of included documents by path relative to the source directory.
+}
+
+func newManifest(config string) *manifest {
+	return &manifest{
+		Version: cacheVersion,
+		Config:  config,
+		Entries: make(map[string]cacheEntry),
+	}
+}
+
+// readManifest reads the manifest of the previous build from outDir. It
+// returns an empty manifest if there was no previous build or if the
+// manifest cannot be parsed.
+func readManifest(outDir string) (*manifest, error) {
+	m := newManifest("")
+	data, err := os.ReadFile(filepath.Join(outDir, CacheFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		// The manifest is an optimization only. Build everything again.
+		return newManifest(""), nil // nolint: nilerr
+	}
+	return m, nil
+}
+
+// cache returns m if its entries may be used by a build with config.
+// Otherwise it returns an empty manifest. Entries of manifests made by
+// another version of mnml or with a different configuration are never
+// used.
+func (m *manifest) cache(config string) *manifest {
+	empty := newManifest(config)
+	if m.Version != empty.Version || m.Config != config || m.Entries == nil {
+		return empty
+	}
+	return m
+}
+
+// write writes the manifest to outDir.
+func (m *manifest) write(outDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(outDir, CacheFile), func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// upToDate returns true if the files created from the source rel with
+// content hash are up to date. srcDir is the source directory.
+func (m *manifest) upToDate(srcDir, rel, hash string) bool {
+	e, ok := m.Entries[rel]
+	if !ok || e.Hash != hash {
+		return false
+	}
+	for dep, depHash := range e.Deps {
+		data, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(dep)))
+		if err != nil || hashOf(data) != depHash {
+			return false
+		}
+	}
+	return true
+}
+
+// hashOf returns the hex encoded SHA-256 hash of data.
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// configHash returns a hash of the configuration of b affecting the files
+// created from single source files.
+func (b *Builder) configHash() string {
+	names := make([]string, 0, len(b.Formats))
+	for _, f := range b.Formats {
+		names = append(names, f.Name+f.Extension)
+	}
+	sort.Strings(names)
+	return hashOf([]byte(strings.Join(names, "\n")))
+}
+
+// recordingFS records the hashes of all files read from it using
+// fs.ReadFile.
+type recordingFS struct {
+	fs.FS
+	deps map[string]string
+}
+
+func (r *recordingFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(r.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	if r.deps == nil {
+		r.deps = make(map[string]string)
+	}
+	r.deps[name] = hashOf(data)
+	return data, nil
+}
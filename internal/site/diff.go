@@ -0,0 +1,212 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffCells limits the size of the table used to compare two files,
+// e.g. to 1024 by 1024 changed lines. Each cell takes four bytes, so a
+// table takes at most 4 MiB for every document built concurrently. Files
+// differing in more lines are shown as replaced completely.
+const maxDiffCells = 1024 * 1024
+
+// isText returns true if data looks like the content of a text file.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+// unifiedDiff returns a unified diff of the file path changing from old to
+// new. A nil old or new denotes a file created or removed, respectively.
+func unifiedDiff(path string, old, new []byte) string {
+	from, to := "a/"+path, "b/"+path
+	if old == nil {
+		from = "/dev/null"
+	}
+	if new == nil {
+		to = "/dev/null"
+	}
+	if !isText(old) || !isText(new) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", from, to)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+	ops := diffLines(splitLines(old), splitLines(new))
+	for _, h := range hunks(ops) {
+		h.write(&sb)
+	}
+	return sb.String()
+}
+
+// splitLines splits data into lines, each including its line break.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is a single line of a diff.
+type diffOp struct {
+	kind byte // One of ' ', '-', or '+'.
+	line string
+}
+
+// diffLines returns the operations turning a into b. It keeps the longest
+// common subsequence of lines unchanged.
+func diffLines(a, b []string) []diffOp {
+	var prefix, suffix []diffOp
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffOp{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]diffOp{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	ops := prefix
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{'+', l})
+		}
+		return append(ops, suffix...)
+	}
+
+	// lcs(i, j) is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	n := len(b) + 1
+	table := make([]int32, (len(a)+1)*n)
+	lcs := func(i, j int) int32 { return table[i*n+j] }
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				table[i*n+j] = lcs(i+1, j+1) + 1
+			case lcs(i+1, j) >= lcs(i, j+1):
+				table[i*n+j] = lcs(i+1, j)
+			default:
+				table[i*n+j] = lcs(i, j+1)
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs(i+1, j) >= lcs(i, j+1):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return append(ops, suffix...)
+}
+
+// hunk is a group of changes together with their context.
+type hunk struct {
+	oldStart, newStart int // Lines the hunk starts at, starting at 1.
+	ops                []diffOp
+}
+
+// hunks groups ops into hunks. Changes less than twice diffContext lines
+// apart share a hunk.
+func hunks(ops []diffOp) []hunk {
+	var (
+		hs         []hunk
+		oldN, newN int // Lines of the old and new file before ops[i].
+	)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldN++
+			newN++
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// Find the end of the hunk: the first run of more than twice
+		// diffContext unchanged lines or the end of ops.
+		end, same := i, 0
+		for ; end < len(ops) && same <= 2*diffContext; end++ {
+			if ops[end].kind == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+		}
+		if same > diffContext {
+			end -= same - diffContext
+		}
+		ctx := i - start
+		hs = append(hs, hunk{oldStart: oldN - ctx + 1, newStart: newN - ctx + 1, ops: ops[start:end]})
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldN++
+			}
+			if op.kind != '-' {
+				newN++
+			}
+		}
+		i = end
+	}
+	return hs
+}
+
+func (h hunk) write(sb *strings.Builder) {
+	var oldLen, newLen int
+	for _, op := range h.ops {
+		if op.kind != '+' {
+			oldLen++
+		}
+		if op.kind != '-' {
+			newLen++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(h.oldStart, oldLen), hunkRange(h.newStart, newLen))
+	for _, op := range h.ops {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of lines of a hunk like diff -u does.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		// An empty range refers to the line before the hunk.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
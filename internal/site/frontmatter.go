@@ -0,0 +1,102 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateLayout is the layout of dates within front matter.
+const DateLayout = "2006-01-02"
+
+const frontMatterDelim = "---"
+
+// frontMatter contains the metadata of a document.
+//
+// Front matter is optional. If present it starts at the first line of a
+// document and is enclosed in lines consisting of three dashes. Each line
+// in between contains a key and a value separated by a colon:
+//
+//	---
+//	title: My first post
+//	date: 2021-05-01
+//	tags: gemini, mnml
+//	draft: true
+//	---
+//
+// Unknown keys are ignored.
+type frontMatter struct {
+	Title string
+	Date  time.Time // Zero if the document has no date.
+	Tags  []string  // Tags of the document in lower case.
+	Draft bool      // The document is not finished yet.
+}
+
+// splitFrontMatter parses the front matter at the beginning of data and
+// returns it together with the remaining document.
+func splitFrontMatter(data []byte) (frontMatter, []byte, error) {
+	var fm frontMatter
+
+	if !bytes.HasPrefix(data, []byte(frontMatterDelim+"\n")) {
+		return fm, data, nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\n")
+		if line == frontMatterDelim {
+			return fm, []byte(strings.Join(lines[i+1:], "")), nil
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := fm.set(line); err != nil {
+			return fm, nil, fmt.Errorf("front matter: line %d: %w", i+1, err)
+		}
+	}
+	return fm, nil, fmt.Errorf("front matter: missing closing %s", frontMatterDelim)
+}
+
+// set sets the field of fm named by the key of line.
+func (fm *frontMatter) set(line string) error {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected key: value: %s", line)
+	}
+	key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	switch key {
+	case "title":
+		fm.Title = value
+	case "date":
+		date, err := time.Parse(DateLayout, value)
+		if err != nil {
+			return fmt.Errorf("invalid date: %s", value)
+		}
+		fm.Date = date
+	case "draft":
+		draft, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid draft: %s", value)
+		}
+		fm.Draft = draft
+	case "tags":
+		fm.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" && !contains(fm.Tags, tag) {
+				fm.Tags = append(fm.Tags, tag)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,796 @@
+// Package site builds a Gemini or Gopher site from a directory of Almost
+// Gemtext documents.
+package site
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/format"
+)
+
+// SourceExtension is the file extension of Almost Gemtext documents.
+const SourceExtension = ".agmi"
+
+// Builder builds a site from the Almost Gemtext documents in a source
+// directory.
+//
+// For each of its formats the Builder creates a separate directory tree
+// within the output directory. The directory is named after the format.
+// Within this tree every Almost Gemtext document of the source directory is
+// converted to the respective format. All other files are copied verbatim.
+// Files and directories whose name starts with a dot are ignored. Source
+// files creating the same output file, e.g. a.agmi and a.gmi for Gemtext,
+// are an error.
+//
+// Include directives within the Almost Gemtext documents are expanded
+// before conversion. Paths starting with a slash are relative to the source
+// directory. Documents meant to be included only may thus be kept in a
+// directory starting with a dot.
+//
+// Documents may start with front matter defining their title, date, and
+// tags. If any document is tagged, the Builder creates an overview of all
+// tags as well as an index page for each tag within TagsDir. The index
+// page of a tag lists all documents tagged with it, newest first. The pages
+// replace any documents of the same name within TagsDir.
+//
+// Drafts and documents dated in the future are excluded from the site,
+// including the tag pages and feeds, unless Drafts or Future are set.
+//
+// The Builder keeps a manifest of the sources of all files it created in
+// CacheFile within the output directory. It does not convert or copy a file
+// again unless the file or any document it includes changed since the
+// previous build. Output files whose content does not change are never
+// rewritten. Their modification times thus stay the same.
+//
+// The manifest lists the files created by the Builder as well. Files created
+// by a previous build but not by the current one are removed. Other files
+// within the output directory are left untouched unless Clean is set.
+type Builder struct {
+	SourceDir string          // Directory containing the Almost Gemtext documents.
+	OutputDir string          // Directory the site is written to.
+	Formats   []format.Format // Formats to create.
+
+	// TagFeeds creates an Atom feed next to the index page of each tag.
+	TagFeeds bool
+
+	// BaseURL is the URL the site is published at. It is required to
+	// create feeds.
+	BaseURL string
+
+	// Author is the author named in feeds. Defaults to the host of
+	// BaseURL.
+	Author string
+
+	Drafts bool      // Include documents marked as draft.
+	Future bool      // Include documents dated in the future.
+	Now    time.Time // Time to compare the dates of documents to. Defaults to the current time.
+
+	// Force ignores the manifest of the previous build and converts all
+	// documents again.
+	Force bool
+
+	// Jobs is the maximum number of files converted concurrently. Defaults
+	// to GOMAXPROCS.
+	Jobs int
+
+	// Clean removes all files within the directories of the formats which
+	// were not created by the build, including files not created by any
+	// Builder.
+	Clean bool
+
+	// DryRun neither writes nor removes any files. The Summary describes
+	// what the build would do.
+	DryRun bool
+
+	// Diff adds a unified diff to each Change of the Summary.
+	Diff bool
+}
+
+// Summary describes the outcome of a build.
+type Summary struct {
+	Documents int       // Number of converted documents.
+	Files     int       // Number of files copied verbatim.
+	Unchanged int       // Number of documents and files not changed since the previous build.
+	Skipped   []Skipped // Documents excluded from the site in the order they were found.
+	Removed   []string  // Files removed from the output directory, relative to it and sorted.
+	Changes   []Change  // Files created, changed, or removed within the output directory, sorted by path.
+}
+
+// ChangeOp describes how a build changed a file.
+type ChangeOp string
+
+// Operations of a Change.
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeModify ChangeOp = "change"
+	ChangeRemove ChangeOp = "remove"
+)
+
+// Change describes a file of the output directory changed by a build.
+type Change struct {
+	Path string   // Path of the file relative to the output directory, separated by slashes.
+	Op   ChangeOp // How the file was changed.
+	Diff string   // Unified diff of the change if the Builder's Diff is set.
+}
+
+// Skipped describes a document excluded from the site.
+type Skipped struct {
+	Path   string // Path of the document relative to the source directory.
+	Reason string // Reason for the exclusion, e.g. draft.
+}
+
+// Build builds the site and returns a summary of the build.
+func (b *Builder) Build() (*Summary, error) {
+	return b.BuildContext(context.Background())
+}
+
+// BuildContext builds the site and returns a summary of the build.
+//
+// BuildContext converts up to Jobs files concurrently. It stops converting
+// files as soon as ctx is done or converting a file failed. The tag pages
+// are created after all documents were converted.
+func (b *Builder) BuildContext(ctx context.Context) (*Summary, error) {
+	const op = "site/Builder.BuildContext"
+
+	if len(b.Formats) == 0 {
+		return nil, fmt.Errorf("%s: no formats", op)
+	}
+	var baseURL *url.URL
+	if b.TagFeeds {
+		u, err := parseBaseURL(b.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		baseURL = u
+	}
+	srcDir, err := filepath.Abs(b.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	outDir, err := filepath.Abs(b.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	now := b.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	config := b.configHash()
+	prev, err := readManifest(outDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	cache := prev.cache(config)
+	if b.Force {
+		cache = newManifest(config)
+	}
+
+	var rels []string
+	err = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == outDir {
+			// Never build the output of a previous run again.
+			return filepath.SkipDir
+		}
+		if path != srcDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		rels = append(rels, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := b.checkCollisions(rels); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	bs := &buildState{
+		Builder: b,
+		srcDir:  srcDir,
+		outDir:  outDir,
+		now:     now,
+		prev:    cache,
+		outputs: make(map[string]bool),
+	}
+	results, err := bs.buildAll(ctx, rels)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Collect the results in the order the files were found. This keeps
+	// the summary and the tag pages independent of scheduling.
+	var (
+		sum   Summary
+		posts []post
+		next  = newManifest(config)
+	)
+	for _, r := range results {
+		switch {
+		case r.skipped != nil:
+			sum.Skipped = append(sum.Skipped, *r.skipped)
+			continue
+		case r.unchanged:
+			sum.Unchanged++
+		case r.post != nil:
+			sum.Documents++
+		default:
+			sum.Files++
+		}
+		if r.post != nil {
+			posts = append(posts, *r.post)
+		}
+		next.Entries[filepath.ToSlash(r.rel)] = r.entry
+	}
+	ti, err := newTagIndex(posts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := bs.buildTags(ti, baseURL); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if sum.Removed, err = bs.prune(prev.Outputs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sum.Changes = bs.changes
+	sort.Slice(sum.Changes, func(i, j int) bool {
+		return sum.Changes[i].Path < sum.Changes[j].Path
+	})
+	next.Outputs = bs.keptOutputs(prev.Outputs)
+	if b.DryRun {
+		return &sum, nil
+	}
+	if err := next.write(outDir); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &sum, nil
+}
+
+// buildState holds the state shared by all files of a build.
+type buildState struct {
+	*Builder
+	srcDir string
+	outDir string
+	now    time.Time
+	prev   *manifest // Manifest of the previous build, if it may be used.
+
+	mu      sync.Mutex
+	outputs map[string]bool // Files created by this build, relative to outDir and separated by slashes.
+	changes []Change
+}
+
+// fileResult describes the outcome of building a single file.
+type fileResult struct {
+	rel       string     // Path of the file relative to the source directory.
+	entry     cacheEntry // Entry of the file in the manifest.
+	post      *post      // Set if the file is a document of the site.
+	unchanged bool       // The file did not change since the previous build.
+	skipped   *Skipped   // Set if the file is a document excluded from the site.
+}
+
+// buildAll builds the files rels using up to Jobs goroutines. It returns
+// the results in the same order as rels.
+func (bs *buildState) buildAll(ctx context.Context, rels []string) ([]fileResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make([]fileResult, len(rels))
+		indexes  = make(chan int)
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for n := 0; n < bs.jobs(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				r, err := bs.buildFile(rels[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = r
+			}
+		}()
+	}
+
+feed:
+	for i := range rels {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkCollisions returns an error if any two of the source files rels
+// create the same file in any format. Files are built concurrently, so the
+// content of such a file would depend on scheduling.
+func (b *Builder) checkCollisions(rels []string) error {
+	for _, f := range b.Formats {
+		sources := make(map[string]string, len(rels))
+		for _, rel := range rels {
+			out := rel
+			if filepath.Ext(rel) == SourceExtension {
+				out = OutputPath(rel, f)
+			}
+			if other, ok := sources[out]; ok {
+				return fmt.Errorf("%s and %s both create %s", other, rel, filepath.Join(f.Name, out))
+			}
+			sources[out] = rel
+		}
+	}
+	return nil
+}
+
+// jobs returns the number of files to build concurrently.
+func (b *Builder) jobs() int {
+	if b.Jobs > 0 {
+		return b.Jobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// buildFile converts or copies the file rel to all formats.
+func (bs *buildState) buildFile(rel string) (fileResult, error) {
+	r := fileResult{rel: rel}
+	if filepath.Ext(rel) != SourceExtension {
+		data, err := os.ReadFile(filepath.Join(bs.srcDir, rel))
+		if err != nil {
+			return r, err
+		}
+		r.entry = cacheEntry{Hash: hashOf(data)}
+		if bs.prev.upToDate(bs.srcDir, filepath.ToSlash(rel), r.entry.Hash) && bs.keepOutputs(rel, false) {
+			r.unchanged = true
+			return r, nil
+		}
+		for _, f := range bs.Formats {
+			err := bs.writeFile(filepath.Join(bs.outDir, f.Name, rel), func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			})
+			if err != nil {
+				return r, err
+			}
+		}
+		return r, nil
+	}
+
+	doc, err := readDocument(bs.srcDir, rel)
+	if err != nil {
+		return r, err
+	}
+	if reason := bs.skipReason(doc.meta, bs.now); reason != "" {
+		r.skipped = &Skipped{Path: doc.path, Reason: reason}
+		return r, nil
+	}
+	r.post = &doc.post
+	hash := hashOf(doc.data)
+	if bs.prev.upToDate(bs.srcDir, doc.path, hash) && bs.keepOutputs(rel, true) {
+		r.entry = bs.prev.Entries[doc.path]
+		r.unchanged = true
+		return r, nil
+	}
+	deps, err := bs.buildDocument(doc)
+	if err != nil {
+		return r, err
+	}
+	r.entry = cacheEntry{Hash: hash, Deps: deps}
+	return r, nil
+}
+
+// document is an Almost Gemtext document read from the source directory.
+type document struct {
+	post
+	srcFile string
+	data    []byte // Content of the document.
+	body    []byte // Content of the document without front matter.
+}
+
+func readDocument(srcDir, rel string) (document, error) {
+	doc := document{srcFile: filepath.Join(srcDir, rel)}
+	data, err := os.ReadFile(doc.srcFile)
+	if err != nil {
+		return doc, err
+	}
+	meta, body, err := splitFrontMatter(data)
+	if err != nil {
+		return doc, fmt.Errorf("%s: %w", doc.srcFile, err)
+	}
+	doc.post = post{path: filepath.ToSlash(rel), meta: meta}
+	doc.data = data
+	doc.body = body
+	return doc, nil
+}
+
+// skipReason returns why a document with front matter meta is excluded
+// from the site. It returns the empty string if the document is included.
+func (b *Builder) skipReason(meta frontMatter, now time.Time) string {
+	switch {
+	case meta.Draft && !b.Drafts:
+		return "draft"
+	case meta.Date.After(now) && !b.Future:
+		return fmt.Sprintf("dated in the future (%s)", meta.Date.Format(DateLayout))
+	default:
+		return ""
+	}
+}
+
+// buildDocument converts doc to all formats. It returns the hashes of all
+// documents included by doc.
+func (bs *buildState) buildDocument(doc document) (map[string]string, error) {
+	srcFile, data, body := doc.srcFile, doc.data, doc.body
+
+	var expanded bytes.Buffer
+	fsys := &recordingFS{FS: os.DirFS(bs.srcDir)}
+	sm, err := agmi.ExpandIncludesWithMap(&expanded, bytes.NewReader(body), fsys, doc.path)
+	if err != nil {
+		skipFrontMatter(err, data[:len(data)-len(body)])
+		return nil, fmt.Errorf("convert %s: %w", srcFile, err)
+	}
+
+	for _, f := range bs.Formats {
+		outFile := filepath.Join(bs.outDir, f.Name, OutputPath(filepath.FromSlash(doc.path), f))
+		err := bs.writeFile(outFile, func(w io.Writer) error {
+			return f.Convert(bytes.NewReader(expanded.Bytes()), w)
+		})
+		if err != nil {
+			sm.Translate(err)
+			skipFrontMatter(err, data[:len(data)-len(body)])
+			return nil, fmt.Errorf("convert %s to %s: %w", srcFile, f.Name, err)
+		}
+	}
+	return fsys.deps, nil
+}
+
+// keepOutputs marks the files created from the source file rel for all
+// formats as created by this build. It returns false if any of them does
+// not exist. doc is true if rel is an Almost Gemtext document.
+func (bs *buildState) keepOutputs(rel string, doc bool) bool {
+	for _, f := range bs.Formats {
+		out := rel
+		if doc {
+			out = OutputPath(rel, f)
+		}
+		path := filepath.Join(bs.outDir, f.Name, out)
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+		bs.addOutput(path)
+	}
+	return true
+}
+
+// addOutput marks the file path as created by this build. It returns path
+// relative to outDir.
+func (bs *buildState) addOutput(path string) string {
+	rel, err := filepath.Rel(bs.outDir, path)
+	if err != nil {
+		// All files are created within outDir.
+		panic(err)
+	}
+	rel = filepath.ToSlash(rel)
+	bs.mu.Lock()
+	bs.outputs[rel] = true
+	bs.mu.Unlock()
+	return rel
+}
+
+// writeFile works like the function writeFile, but marks path as created
+// by this build and records the change. It does not write path during a dry
+// run.
+func (bs *buildState) writeFile(path string, write func(w io.Writer) error) error {
+	rel := bs.addOutput(path)
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	old, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		bs.addChange(rel, ChangeCreate, nil, buf.Bytes())
+	case err != nil:
+		return err
+	case !bytes.Equal(old, buf.Bytes()):
+		bs.addChange(rel, ChangeModify, old, buf.Bytes())
+	default:
+		return nil
+	}
+	if bs.DryRun {
+		return nil
+	}
+	return writeFile(path, func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+}
+
+// addChange records the change of the file rel from old to new.
+func (bs *buildState) addChange(rel string, op ChangeOp, old, new []byte) {
+	c := Change{Path: rel, Op: op}
+	if bs.Diff {
+		c.Diff = unifiedDiff(rel, old, new)
+	}
+	bs.mu.Lock()
+	bs.changes = append(bs.changes, c)
+	bs.mu.Unlock()
+}
+
+// prune removes all files within the directories of the formats which were
+// not created by this build, but either by a previous one listed in
+// prevOutputs or, if Clean is set, by anyone. It returns the removed files
+// relative to outDir.
+func (bs *buildState) prune(prevOutputs []string) ([]string, error) {
+	stale := make(map[string]bool)
+	for _, out := range prevOutputs {
+		if bs.inFormatDir(out) && !bs.outputs[out] {
+			stale[out] = true
+		}
+	}
+	if bs.Clean {
+		for _, f := range bs.Formats {
+			err := filepath.WalkDir(filepath.Join(bs.outDir, f.Name), func(path string, d fs.DirEntry, err error) error {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(bs.outDir, path)
+				if err != nil {
+					return err
+				}
+				if rel = filepath.ToSlash(rel); !bs.outputs[rel] {
+					stale[rel] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var removed []string
+	for rel := range stale {
+		removed = append(removed, rel)
+	}
+	sort.Strings(removed)
+	for _, rel := range removed {
+		var old []byte
+		if bs.Diff {
+			var err error
+			if old, err = os.ReadFile(filepath.Join(bs.outDir, filepath.FromSlash(rel))); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+		}
+		bs.addChange(rel, ChangeRemove, old, nil)
+	}
+	if bs.DryRun {
+		return removed, nil
+	}
+	for _, rel := range removed {
+		path := filepath.Join(bs.outDir, filepath.FromSlash(rel))
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		// Remove directories left empty, but never a format directory.
+		for dir := filepath.Dir(path); filepath.Dir(dir) != bs.outDir; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// keptOutputs returns the files to list in the manifest of this build. These
+// are the files created by this build and those of prevOutputs which are not
+// within the directory of any format of this build. Invalid paths in
+// prevOutputs are dropped.
+func (bs *buildState) keptOutputs(prevOutputs []string) []string {
+	var outputs []string
+	for _, out := range prevOutputs {
+		if fs.ValidPath(out) && !bs.inFormatDir(out) {
+			outputs = append(outputs, out)
+		}
+	}
+	for out := range bs.outputs {
+		outputs = append(outputs, out)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// inFormatDir returns true if the file rel relative to outDir is within the
+// directory of any format of this build. rel may be taken from a manifest
+// and is thus validated first.
+func (bs *buildState) inFormatDir(rel string) bool {
+	if !fs.ValidPath(rel) {
+		return false
+	}
+	for _, f := range bs.Formats {
+		if strings.HasPrefix(rel, f.Name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTags creates the tag overview and the index pages of all tags in ti.
+// It creates a feed for each tag if baseURL is not nil.
+func (bs *buildState) buildTags(ti tagIndex, baseURL *url.URL) error {
+	if len(ti) == 0 {
+		return nil
+	}
+	for _, f := range bs.Formats {
+		tagsDir := filepath.Join(bs.outDir, f.Name, TagsDir)
+		err := bs.convertGenerated(f, filepath.Join(tagsDir, OutputPath(tagsOverview+SourceExtension, f)),
+			func(w io.Writer) error {
+				return ti.writeOverview(w, f)
+			})
+		if err != nil {
+			return err
+		}
+		for _, tag := range ti.tags() {
+			tag := tag
+			err := bs.convertGenerated(f, filepath.Join(tagsDir, tagPage(tag, f)), func(w io.Writer) error {
+				return ti.writeTagPage(w, tag, f)
+			})
+			if err != nil {
+				return err
+			}
+			if baseURL == nil {
+				continue
+			}
+			err = bs.writeFile(filepath.Join(tagsDir, tagFeed(tag)), func(w io.Writer) error {
+				return ti.writeFeed(w, tag, f, baseURL, bs.author(baseURL))
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// author returns the author of the feeds of a site published at baseURL.
+func (b *Builder) author(baseURL *url.URL) string {
+	if b.Author != "" {
+		return b.Author
+	}
+	return baseURL.Hostname()
+}
+
+// skipFrontMatter corrects the position of err if it is a SyntaxError
+// within a document whose front matter was removed.
+func skipFrontMatter(err error, frontMatter []byte) {
+	var serr *agmi.SyntaxError
+	if errors.As(err, &serr) && serr.File == "" {
+		serr.Pos.Line += bytes.Count(frontMatter, []byte("\n"))
+		serr.Pos.Offset += len(frontMatter)
+	}
+}
+
+// OutputPath returns the path of the document in format f created from the
+// Almost Gemtext document at path.
+func OutputPath(path string, f format.Format) string {
+	return strings.TrimSuffix(path, SourceExtension) + f.Extension
+}
+
+// parseBaseURL parses the absolute URL s. The path of the returned URL
+// always ends in a slash.
+func parseBaseURL(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, fmt.Errorf("feeds require a base URL")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("base URL is not absolute: %s", s)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// convertGenerated converts the Almost Gemtext document written by generate
+// to format f and writes it to outFile.
+func (bs *buildState) convertGenerated(f format.Format, outFile string, generate func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := generate(&buf); err != nil {
+		return err
+	}
+	err := bs.writeFile(outFile, func(w io.Writer) error {
+		return f.Convert(&buf, w)
+	})
+	if err != nil {
+		return fmt.Errorf("convert %s to %s: %w", outFile, f.Name, err)
+	}
+	return nil
+}
+
+// writeFile passes a buffer to write and writes its content to the file
+// path. It leaves path untouched if it exists and has the same content
+// already.
+func writeFile(path string, write func(w io.Writer) error) (err error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return nil
+	}
+
+	out, err := create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = out.Write(buf.Bytes())
+	return err
+}
+
+func create(path string) (*os.File, error) {
+	// The site is meant to be published. Allow everyone to read it.
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { // nolint: gosec
+		return nil, err
+	}
+	return os.Create(path)
+}
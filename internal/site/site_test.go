@@ -0,0 +1,700 @@
+package site_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fhofherr/mnml/agmi"
+	"github.com/fhofherr/mnml/format"
+	"github.com/fhofherr/mnml/internal/site"
+	"github.com/fhofherr/mnml/internal/testsupport"
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/fhofherr/mnml/gemtext"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{
+		SourceDir: filepath.Join("testdata", t.Name(), "src"),
+		OutputDir: tempDir,
+		Formats:   []format.Format{gemtext},
+	}
+	if _, err := b.Build(); !assert.NoError(t, err) {
+		return
+	}
+
+	assertFileContent(t, "# My capsule\n\n=> posts/first.gmi First post\n", tempDir, "gemtext", "index.gmi")
+	assertFileContent(t, "# First post\n\nThis is the first post.\n\n=> /index.gmi Home\n",
+		tempDir, "gemtext", "posts", "first.gmi")
+	assertFileContent(t, "not an image\n", tempDir, "gemtext", "posts", "image.png")
+	assert.NoFileExists(t, filepath.Join(tempDir, "gemtext", "posts", "first.agmi"))
+	assert.NoDirExists(t, filepath.Join(tempDir, "gemtext", ".hidden"))
+	assert.NoDirExists(t, filepath.Join(tempDir, "gemtext", ".partials"))
+}
+
+func TestBuilder_Build_MissingInclude(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	if !assert.NoError(t, os.Mkdir(srcDir, 0700)) {
+		return
+	}
+	err := os.WriteFile(filepath.Join(srcDir, "index.agmi"), []byte("---\ntitle: Home\n---\n# Home\n\n<!-- include: missing.agmi -->\n"), 0600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{
+		SourceDir: srcDir,
+		OutputDir: filepath.Join(tempDir, "out"),
+		Formats:   []format.Format{gemtext},
+	}
+	_, err = b.Build()
+
+	var serr *agmi.SyntaxError
+	if assert.True(t, errors.As(err, &serr)) {
+		assert.Equal(t, agmi.SyntaxErrorInclude, serr.Kind)
+		assert.Equal(t, 6, serr.Pos.Line)
+	}
+}
+
+func TestBuilder_Build_Tags(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{
+		SourceDir: filepath.Join("testdata", t.Name(), "src"),
+		OutputDir: tempDir,
+		Formats:   []format.Format{gemtext},
+		TagFeeds:  true,
+		BaseURL:   "gemini://example.com/~me",
+	}
+	if _, err := b.Build(); !assert.NoError(t, err) {
+		return
+	}
+
+	assertFileContent(t, "# First post\n\nHello.\n", tempDir, "gemtext", "posts", "first.gmi")
+	assertFileContent(t, "# Tags\n\n=> gemini.gmi gemini (2)\n=> mnml.gmi mnml (1)\n",
+		tempDir, "gemtext", site.TagsDir, "index.gmi")
+	assertFileContent(t, "# Posts tagged gemini\n\n"+
+		"=> ../posts/second.gmi 2021-06-01 second\n"+
+		"=> ../posts/first.gmi 2021-05-01 First post\n\n"+
+		"=> index.gmi All tags\n",
+		tempDir, "gemtext", site.TagsDir, "gemini.gmi")
+
+	feed, err := os.ReadFile(filepath.Join(tempDir, "gemtext", site.TagsDir, "mnml.xml"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(feed), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, string(feed), "<id>gemini://example.com/~me/tags/mnml.gmi</id>")
+	assert.Contains(t, string(feed), "<updated>2021-05-01T00:00:00Z</updated>")
+	assert.Contains(t, string(feed), `<link href="gemini://example.com/~me/posts/first.gmi"></link>`)
+	assert.Contains(t, string(feed), "<author>\n    <name>example.com</name>\n  </author>")
+	assert.Contains(t, string(feed), `<link href="gemini://example.com/~me/tags/mnml.xml" rel="self"></link>`)
+
+	b.Author = "Jane Doe"
+	if _, err := b.Build(); !assert.NoError(t, err) {
+		return
+	}
+	feed, err = os.ReadFile(filepath.Join(tempDir, "gemtext", site.TagsDir, "mnml.xml"))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(feed), "<name>Jane Doe</name>")
+	}
+}
+
+func TestBuilder_Build_TagNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name: "reserved and escaped names",
+			files: map[string]string{
+				"a.agmi": "---\ntags: Index, C++\n---\n# A\n",
+			},
+			expected: map[string]string{
+				"gemtext/a.gmi":           "# A\n",
+				"gemtext/tags/index.gmi":  "# Tags\n\n=> c--.gmi c++ (1)\n=> _index.gmi index (1)\n",
+				"gemtext/tags/_index.gmi": "# Posts tagged index\n\n=> ../a.gmi a\n\n=> index.gmi All tags\n",
+				"gemtext/tags/c--.gmi":    "# Posts tagged c++\n\n=> ../a.gmi a\n\n=> index.gmi All tags\n",
+			},
+		},
+		{
+			name: "file name with spaces",
+			files: map[string]string{
+				"posts/my post.agmi": "---\ntags: a\n---\n# A\n",
+			},
+			expected: map[string]string{
+				"gemtext/posts/my post.gmi": "# A\n",
+				"gemtext/tags/index.gmi":    "# Tags\n\n=> a.gmi a (1)\n",
+				"gemtext/tags/a.gmi":        "# Posts tagged a\n\n=> ../posts/my%20post.gmi my post\n\n=> index.gmi All tags\n",
+			},
+		},
+		{
+			name: "title containing a comment",
+			files: map[string]string{
+				"a.agmi": "---\ntitle: a <!-- b --> c\ntags: a\n---\n# A\n",
+			},
+			expected: map[string]string{
+				"gemtext/a.gmi":          "# A\n",
+				"gemtext/tags/index.gmi": "# Tags\n\n=> a.gmi a (1)\n",
+				"gemtext/tags/a.gmi":     "# Posts tagged a\n\n=> ../a.gmi a <!-- b --> c\n\n=> index.gmi All tags\n",
+			},
+		},
+		{
+			name: "colliding tags",
+			files: map[string]string{
+				"a.agmi": "---\ntags: c++\n---\n# A\n",
+				"b.agmi": "---\ntags: c--\n---\n# B\n",
+			},
+			err: `tags "c++" and "c--" both have the index page tags/c--`,
+		},
+		{
+			name: "tag colliding with the escaped overview",
+			files: map[string]string{
+				"a.agmi": "---\ntags: index, _index\n---\n# A\n",
+			},
+			err: `tags "_index" and "index" both have the index page tags/_index`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanUp := testsupport.MkdirTemp(t)
+			defer cleanUp()
+
+			srcDir := filepath.Join(tempDir, "src")
+			outDir := filepath.Join(tempDir, "out")
+			testsupport.WriteFiles(t, srcDir, tt.files)
+			gemtext, ok := format.Lookup("gemtext")
+			if !assert.True(t, ok) {
+				return
+			}
+			b := site.Builder{SourceDir: srcDir, OutputDir: outDir, Formats: []format.Format{gemtext}}
+			_, err := b.Build()
+			if tt.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			actual := readFiles(t, outDir)
+			delete(actual, site.CacheFile)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestBuilder_Build_InvalidFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "invalid date",
+			content: "---\ndate: yesterday\n---\n",
+			err:     "front matter: line 2: invalid date: yesterday",
+		},
+		{
+			name:    "missing colon",
+			content: "---\ntitle\n---\n",
+			err:     "front matter: line 2: expected key: value: title",
+		},
+		{
+			name:    "not closed",
+			content: "---\ntitle: Open\n",
+			err:     "front matter: missing closing ---",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanUp := testsupport.MkdirTemp(t)
+			defer cleanUp()
+
+			srcDir := filepath.Join(tempDir, "src")
+			if !assert.NoError(t, os.Mkdir(srcDir, 0700)) {
+				return
+			}
+			err := os.WriteFile(filepath.Join(srcDir, "index.agmi"), []byte(tt.content), 0600)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			gemtext, ok := format.Lookup("gemtext")
+			if !assert.True(t, ok) {
+				return
+			}
+			b := site.Builder{
+				SourceDir: srcDir,
+				OutputDir: filepath.Join(tempDir, "out"),
+				Formats:   []format.Format{gemtext},
+			}
+			_, err = b.Build()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestBuilder_Build_Drafts(t *testing.T) {
+	tests := []struct {
+		name     string
+		drafts   bool
+		future   bool
+		expected []string
+		skipped  []site.Skipped
+	}{
+		{
+			name:     "skip drafts and future documents",
+			expected: []string{"published.gmi"},
+			skipped: []site.Skipped{
+				{Path: "posts/draft.agmi", Reason: "draft"},
+				{Path: "posts/future.agmi", Reason: "dated in the future (2030-01-01)"},
+			},
+		},
+		{
+			name:     "include drafts",
+			drafts:   true,
+			expected: []string{"draft.gmi", "published.gmi"},
+			skipped: []site.Skipped{
+				{Path: "posts/future.agmi", Reason: "dated in the future (2030-01-01)"},
+			},
+		},
+		{
+			name:     "include drafts and future documents",
+			drafts:   true,
+			future:   true,
+			expected: []string{"draft.gmi", "future.gmi", "published.gmi"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanUp := testsupport.MkdirTemp(t)
+			defer cleanUp()
+
+			gemtext, ok := format.Lookup("gemtext")
+			if !assert.True(t, ok) {
+				return
+			}
+			b := site.Builder{
+				SourceDir: filepath.Join("testdata", "TestBuilder_Build_Drafts", "src"),
+				OutputDir: tempDir,
+				Formats:   []format.Format{gemtext},
+				Drafts:    tt.drafts,
+				Future:    tt.future,
+				Now:       time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC),
+			}
+			sum, err := b.Build()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.skipped, sum.Skipped)
+			assert.Equal(t, len(tt.expected), sum.Documents)
+
+			entries, err := os.ReadDir(filepath.Join(tempDir, "gemtext", "posts"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			var actual []string
+			for _, e := range entries {
+				actual = append(actual, e.Name())
+			}
+			assert.Equal(t, tt.expected, actual)
+
+			// Skipped documents are not listed on tag pages either.
+			tagPage, err := os.ReadFile(filepath.Join(tempDir, "gemtext", site.TagsDir, "wip.gmi"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, len(tt.expected), strings.Count(string(tagPage), "../posts/"))
+		})
+	}
+}
+
+func TestBuilder_Build_Cache(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	outDir := filepath.Join(tempDir, "out")
+	testsupport.WriteFiles(t, srcDir, map[string]string{
+		"index.agmi":            "# Home\n\n<!-- include: .partials/footer.agmi -->\n",
+		"other.agmi":            "# Other\n",
+		"image.png":             "not an image\n",
+		".partials/footer.agmi": "Footer\n",
+	})
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{SourceDir: srcDir, OutputDir: outDir, Formats: []format.Format{gemtext}}
+	build := func(expected site.Summary) {
+		t.Helper()
+
+		sum, err := b.Build()
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, *sum)
+		}
+	}
+	outFiles := []string{"index.gmi", "other.gmi", "image.png"}
+	past := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assertModTimes := func(expected time.Time, names ...string) {
+		t.Helper()
+
+		for _, name := range names {
+			fi, err := os.Stat(filepath.Join(outDir, "gemtext", name))
+			if assert.NoError(t, err) {
+				assert.True(t, fi.ModTime().Equal(expected), "%s modified at %v", name, fi.ModTime())
+			}
+		}
+	}
+
+	build(site.Summary{Documents: 2, Files: 1, Changes: []site.Change{
+		{Path: "gemtext/image.png", Op: site.ChangeCreate},
+		{Path: "gemtext/index.gmi", Op: site.ChangeCreate},
+		{Path: "gemtext/other.gmi", Op: site.ChangeCreate},
+	}})
+	assert.FileExists(t, filepath.Join(outDir, site.CacheFile))
+	for _, name := range outFiles {
+		if !assert.NoError(t, os.Chtimes(filepath.Join(outDir, "gemtext", name), past, past)) {
+			return
+		}
+	}
+
+	build(site.Summary{Unchanged: 3})
+	assertModTimes(past, outFiles...)
+
+	testsupport.WriteFiles(t, srcDir, map[string]string{".partials/footer.agmi": "Changed footer\n"})
+	build(site.Summary{Documents: 1, Unchanged: 2, Changes: []site.Change{
+		{Path: "gemtext/index.gmi", Op: site.ChangeModify},
+	}})
+	assertFileContent(t, "# Home\n\nChanged footer\n", outDir, "gemtext", "index.gmi")
+	assertModTimes(past, "other.gmi", "image.png")
+
+	b.Force = true
+	build(site.Summary{Documents: 2, Files: 1})
+	assertModTimes(past, "other.gmi", "image.png")
+}
+
+func TestBuilder_Build_Jobs(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	files := map[string]string{".partials/footer.agmi": "=> /index.agmi Home\n"}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("posts/post%02d.agmi", i)] = fmt.Sprintf(
+			"---\ndate: 2021-05-%02d\ntags: gemini, post%d\ndraft: %t\n---\n# Post %d\n\n<!-- include: /.partials/footer.agmi -->\n",
+			i%28+1, i%3, i%10 == 0, i)
+		files[fmt.Sprintf("posts/file%02d.txt", i)] = fmt.Sprintf("File %d\n", i)
+	}
+	testsupport.WriteFiles(t, srcDir, files)
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	build := func(jobs int) (*site.Summary, map[string]string) {
+		outDir := filepath.Join(tempDir, fmt.Sprintf("out%d", jobs))
+		b := site.Builder{
+			SourceDir: srcDir,
+			OutputDir: outDir,
+			Formats:   []format.Format{gemtext},
+			TagFeeds:  true,
+			BaseURL:   "gemini://example.com/",
+			Jobs:      jobs,
+		}
+		sum, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum, readFiles(t, outDir)
+	}
+
+	// Run with the race detector to find data races between the jobs.
+	expectedSum, expectedFiles := build(1)
+	actualSum, actualFiles := build(8)
+	assert.Equal(t, expectedSum, actualSum)
+	assert.Equal(t, expectedFiles, actualFiles)
+	assert.Equal(t, 45, actualSum.Documents)
+	assert.Len(t, actualSum.Skipped, 5)
+}
+
+func TestBuilder_Build_Collision(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	outDir := filepath.Join(tempDir, "out")
+	testsupport.WriteFiles(t, srcDir, map[string]string{
+		"a.agmi": "# Converted\n",
+		"a.gmi":  "# Copied\n",
+	})
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{
+		SourceDir: srcDir,
+		OutputDir: outDir,
+		Formats:   []format.Format{gemtext},
+		Jobs:      8,
+	}
+	_, err := b.Build()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a.agmi and a.gmi both create gemtext/a.gmi")
+	}
+	assert.NoDirExists(t, outDir)
+}
+
+func TestBuilder_BuildContext_Canceled(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{
+		SourceDir: filepath.Join("testdata", "TestBuilder_Build", "src"),
+		OutputDir: tempDir,
+		Formats:   []format.Format{gemtext},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.BuildContext(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.NoFileExists(t, filepath.Join(tempDir, site.CacheFile))
+}
+
+func TestBuilder_Build_Prune(t *testing.T) {
+	tests := []struct {
+		name      string
+		clean     bool
+		dryRun    bool
+		removed   []string
+		remaining []string
+	}{
+		{
+			name:      "remove outputs of renamed documents",
+			removed:   []string{"gemtext/posts/old.gmi", "gemtext/tags/gemini.gmi", "gemtext/tags/index.gmi"},
+			remaining: []string{"gemtext/foreign.gmi", "gemtext/posts/new.gmi"},
+		},
+		{
+			name:  "remove files not created by mnml",
+			clean: true,
+			removed: []string{
+				"gemtext/foreign.gmi", "gemtext/posts/old.gmi", "gemtext/tags/gemini.gmi", "gemtext/tags/index.gmi",
+			},
+			remaining: []string{"gemtext/posts/new.gmi"},
+		},
+		{
+			name:   "dry run",
+			clean:  true,
+			dryRun: true,
+			removed: []string{
+				"gemtext/foreign.gmi", "gemtext/posts/old.gmi", "gemtext/tags/gemini.gmi", "gemtext/tags/index.gmi",
+			},
+			remaining: []string{
+				"gemtext/foreign.gmi", "gemtext/posts/old.gmi", "gemtext/tags/gemini.gmi", "gemtext/tags/index.gmi",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir, cleanUp := testsupport.MkdirTemp(t)
+			defer cleanUp()
+
+			srcDir := filepath.Join(tempDir, "src")
+			outDir := filepath.Join(tempDir, "out")
+			testsupport.WriteFiles(t, srcDir, map[string]string{"posts/old.agmi": "---\ntags: gemini\n---\n# Post\n"})
+			testsupport.WriteFiles(t, outDir, map[string]string{"gemtext/foreign.gmi": "# Not created by mnml\n"})
+
+			gemtext, ok := format.Lookup("gemtext")
+			if !assert.True(t, ok) {
+				return
+			}
+			b := site.Builder{SourceDir: srcDir, OutputDir: outDir, Formats: []format.Format{gemtext}}
+			if _, err := b.Build(); !assert.NoError(t, err) {
+				return
+			}
+
+			if !assert.NoError(t, os.Rename(filepath.Join(srcDir, "posts", "old.agmi"), filepath.Join(srcDir, "posts", "new.agmi"))) {
+				return
+			}
+			testsupport.WriteFiles(t, srcDir, map[string]string{"posts/new.agmi": "# Post\n"})
+			b.Clean = tt.clean
+			b.DryRun = tt.dryRun
+			sum, err := b.Build()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.removed, sum.Removed)
+
+			var remaining []string
+			for name := range readFiles(t, outDir) {
+				if name != site.CacheFile {
+					remaining = append(remaining, name)
+				}
+			}
+			sort.Strings(remaining)
+			assert.Equal(t, tt.remaining, remaining)
+			if !tt.dryRun {
+				assert.NoDirExists(t, filepath.Join(outDir, "gemtext", site.TagsDir))
+			}
+		})
+	}
+}
+
+func TestBuilder_Build_PruneInvalidOutputs(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	outDir := filepath.Join(tempDir, "out")
+	testsupport.WriteFiles(t, srcDir, map[string]string{"index.agmi": "# Index\n"})
+	testsupport.WriteFiles(t, tempDir, map[string]string{"victim.txt": "Keep me\n"})
+	testsupport.WriteFiles(t, outDir, map[string]string{
+		site.CacheFile: `{"outputs": ["gemtext/../../victim.txt", "gemtext/./x.gmi", "/gemtext/x.gmi"]}`,
+	})
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{SourceDir: srcDir, OutputDir: outDir, Formats: []format.Format{gemtext}, Clean: true}
+	sum, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, sum.Removed)
+	assertFileContent(t, "Keep me\n", tempDir, "victim.txt")
+
+	cache, err := os.ReadFile(filepath.Join(outDir, site.CacheFile))
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(cache), "victim.txt")
+	}
+}
+
+func TestBuilder_Build_Diff(t *testing.T) {
+	tempDir, cleanUp := testsupport.MkdirTemp(t)
+	defer cleanUp()
+
+	srcDir := filepath.Join(tempDir, "src")
+	outDir := filepath.Join(tempDir, "out")
+	testsupport.WriteFiles(t, srcDir, map[string]string{"old.agmi": "# Old\n", "image.png": "\x89PNG\x00"})
+
+	gemtext, ok := format.Lookup("gemtext")
+	if !assert.True(t, ok) {
+		return
+	}
+	b := site.Builder{SourceDir: srcDir, OutputDir: outDir, Formats: []format.Format{gemtext}, Diff: true}
+	sum, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []site.Change{
+		{Path: "gemtext/image.png", Op: site.ChangeCreate, Diff: "Binary files /dev/null and b/gemtext/image.png differ\n"},
+		{Path: "gemtext/old.gmi", Op: site.ChangeCreate, Diff: "--- /dev/null\n+++ b/gemtext/old.gmi\n@@ -0,0 +1 @@\n+# Old\n"},
+	}, sum.Changes)
+
+	if !assert.NoError(t, os.Rename(filepath.Join(srcDir, "old.agmi"), filepath.Join(srcDir, "new.agmi"))) {
+		return
+	}
+	b.DryRun = true
+	sum, err = b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []site.Change{
+		{Path: "gemtext/new.gmi", Op: site.ChangeCreate, Diff: "--- /dev/null\n+++ b/gemtext/new.gmi\n@@ -0,0 +1 @@\n+# Old\n"},
+		{Path: "gemtext/old.gmi", Op: site.ChangeRemove, Diff: "--- a/gemtext/old.gmi\n+++ /dev/null\n@@ -1 +0,0 @@\n-# Old\n"},
+	}, sum.Changes)
+	assert.FileExists(t, filepath.Join(outDir, "gemtext", "old.gmi"))
+	assert.NoFileExists(t, filepath.Join(outDir, "gemtext", "new.gmi"))
+}
+
+func TestBuilder_Build_NoFormats(t *testing.T) {
+	b := site.Builder{
+		SourceDir: filepath.Join("testdata", "TestBuilder_Build", "src"),
+		OutputDir: "unused",
+	}
+	_, err := b.Build()
+	assert.Error(t, err)
+}
+
+func assertFileContent(t *testing.T, expected string, elem ...string) {
+	t.Helper()
+
+	actual, err := os.ReadFile(filepath.Join(elem...))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, expected, string(actual))
+}
+
+// readFiles returns the contents of all files within dir by their paths
+// relative to dir.
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
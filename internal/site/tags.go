@@ -0,0 +1,227 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fhofherr/mnml/format"
+)
+
+// TagsDir is the directory within the output of each format containing the
+// tag overview and the index pages of the individual tags.
+const TagsDir = "tags"
+
+// tagsOverview is the name of the overview of all tags within TagsDir,
+// without extension.
+const tagsOverview = "index"
+
+// post is an Almost Gemtext document of the site.
+type post struct {
+	path string // Path of the document relative to the source directory, separated by slashes.
+	meta frontMatter
+}
+
+// title returns the title of the post. It defaults to the file name if the
+// front matter does not define a title.
+func (p post) title() string {
+	if p.meta.Title != "" {
+		return p.meta.Title
+	}
+	return strings.TrimSuffix(path.Base(p.path), SourceExtension)
+}
+
+// tagIndex maps each tag to the posts tagged with it. The posts are sorted
+// by date, newest first. Posts without a date come last.
+type tagIndex map[string][]post
+
+// newTagIndex creates the tagIndex of posts. It returns an error if the
+// index pages of two tags would have the same file name.
+func newTagIndex(posts []post) (tagIndex, error) {
+	ti := make(tagIndex)
+	for _, p := range posts {
+		for _, tag := range p.meta.Tags {
+			ti[tag] = append(ti[tag], p)
+		}
+	}
+	names := make(map[string]string, len(ti))
+	for _, tag := range ti.tags() {
+		name := tagName(tag)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("tags %q and %q both have the index page %s", other, tag, path.Join(TagsDir, name))
+		}
+		names[name] = tag
+	}
+	for _, tagged := range ti {
+		sort.SliceStable(tagged, func(i, j int) bool {
+			a, b := tagged[i].meta.Date, tagged[j].meta.Date
+			if a.Equal(b) {
+				return tagged[i].path < tagged[j].path
+			}
+			return a.After(b)
+		})
+	}
+	return ti, nil
+}
+
+// tags returns all tags in alphabetical order.
+func (ti tagIndex) tags() []string {
+	tags := make([]string, 0, len(ti))
+	for tag := range ti {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// writeOverview writes an Almost Gemtext document linking to the index
+// pages of all tags in format f to w.
+func (ti tagIndex) writeOverview(w io.Writer, f format.Format) error {
+	var sb strings.Builder
+
+	sb.WriteString("# Tags\n\n")
+	for _, tag := range ti.tags() {
+		fmt.Fprintf(&sb, "=> %s %s (%d)\n", tagPage(tag, f), escapeText(tag), len(ti[tag]))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeTagPage writes an Almost Gemtext document linking to all posts
+// tagged with tag in format f to w.
+func (ti tagIndex) writeTagPage(w io.Writer, tag string, f format.Format) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# Posts tagged %s\n\n", escapeText(tag))
+	for _, p := range ti[tag] {
+		sb.WriteString("=> " + tagRelPath(p, f) + " ")
+		if !p.meta.Date.IsZero() {
+			sb.WriteString(p.meta.Date.Format(DateLayout) + " ")
+		}
+		sb.WriteString(escapeText(p.title()) + "\n")
+	}
+	fmt.Fprintf(&sb, "\n=> %s All tags\n", OutputPath(tagsOverview+SourceExtension, f))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeFeed writes an Atom feed of all posts tagged with tag in format f to
+// w. Posts without a date are not part of the feed. baseURL is the URL the
+// output of f is published at. author is the author of the feed.
+func (ti tagIndex) writeFeed(w io.Writer, tag string, f format.Format, baseURL *url.URL, author string) error {
+	feed := atomFeed{
+		ID:     resolve(baseURL, path.Join(TagsDir, tagPage(tag, f))),
+		Title:  fmt.Sprintf("Posts tagged %s", tag),
+		Author: atomPerson{Name: author},
+	}
+	feed.Links = []atomLink{
+		{Href: feed.ID},
+		{Href: resolve(baseURL, path.Join(TagsDir, tagFeed(tag))), Rel: "self"},
+	}
+	for _, p := range ti[tag] {
+		if p.meta.Date.IsZero() {
+			continue
+		}
+		uri := resolve(baseURL, OutputPath(p.path, f))
+		entry := atomEntry{ID: uri, Title: p.title(), Updated: atomDate(p.meta.Date)}
+		entry.Link.Href = uri
+		feed.Entries = append(feed.Entries, entry)
+		if entry.Updated > feed.Updated {
+			feed.Updated = entry.Updated
+		}
+	}
+	if feed.Updated == "" {
+		feed.Updated = atomDate(time.Unix(0, 0))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tagPage returns the file name of the index page of tag in format f.
+func tagPage(tag string, f format.Format) string {
+	return OutputPath(tagName(tag)+SourceExtension, f)
+}
+
+// tagFeed returns the file name of the feed of tag.
+func tagFeed(tag string) string {
+	return tagName(tag) + ".xml"
+}
+
+// tagName returns the file name of the index page and the feed of tag
+// without extension. It replaces all characters not allowed in file names
+// by dashes. A leading underscore is added to the tag index, since the
+// overview of all tags uses this name already.
+func tagName(tag string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, tag)
+	if name == tagsOverview {
+		return "_" + name
+	}
+	return name
+}
+
+// tagRelPath returns the path of post p in format f relative to TagsDir. The
+// path is escaped for use as the URI of a link.
+func tagRelPath(p post, f format.Format) string {
+	return (&url.URL{Path: "../" + OutputPath(p.path, f)}).EscapedPath()
+}
+
+// escapeText escapes all open comment symbols within s, so that text taken
+// from the front matter is published as is.
+func escapeText(s string) string {
+	return strings.ReplaceAll(s, "<!--", `\<!--`)
+}
+
+// resolve returns the URL of the file at path relative to baseURL.
+func resolve(baseURL *url.URL, p string) string {
+	return baseURL.ResolveReference(&url.URL{Path: p}).String()
+}
+
+func atomDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
@@ -8,13 +8,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/fhofherr/mnml/format"
 	"github.com/stretchr/testify/assert"
 )
 
-// Converter defines a type that is capable from converting some input to
-// some output.
-type Converter func(in io.Reader, out io.Writer) error
-
 // ConverterTest is a test case for a conversion from one file format
 // to another.
 //
@@ -28,7 +25,7 @@ type ConverterTest struct {
 	InputFile    string // Input file for the test case.
 	ExpectedFile string // File containing expected output.
 
-	Converter Converter // The converter to test.
+	Converter format.Converter // The converter to test.
 }
 
 // Run runs the converter test case.
@@ -85,7 +82,7 @@ func (tt *ConverterTest) Run(t *testing.T) {
 //
 // All expectation files created by converter tests have the name of the
 // respective input file with the suffix .golden appended to them.
-func FindConverterTests(t *testing.T, dir, glob string, c Converter) []*ConverterTest {
+func FindConverterTests(t *testing.T, dir, glob string, c format.Converter) []*ConverterTest {
 	var tests []*ConverterTest // nolint: prealloc
 
 	entries, err := os.ReadDir(dir)
@@ -2,6 +2,7 @@ package testsupport
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -24,3 +25,20 @@ func MkdirTemp(t *testing.T) (string, func()) {
 		}
 	}
 }
+
+// WriteFiles writes files to dir. The keys of files are the paths of the
+// files relative to dir, separated by slashes. Missing directories are
+// created.
+func WriteFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package testsupport
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// AddSeedCorpus adds the Almost Gemtext specification and all Almost Gemtext
+// and Gemtext documents found in testdata directories of the project to the
+// seed corpus of f.
+func AddSeedCorpus(f *testing.F) {
+	f.Helper()
+
+	root := ProjectRoot(f)
+	files := []string{filepath.Join(root, "docs", "almost_gemtext.agmi")}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && (filepath.Ext(path) == ".agmi" || filepath.Ext(path) == ".gmi") && strings.Contains(filepath.ToSlash(path), "/testdata/") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
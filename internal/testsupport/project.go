@@ -9,7 +9,7 @@ import (
 // ProjectRoot searches from the current working directory upwards until it
 // finds a go.mod file. The directory containing the go.mod file is then
 // assumed to be the root of this project.
-func ProjectRoot(t *testing.T) string {
+func ProjectRoot(t testing.TB) string {
 	t.Helper()
 
 	home, err := os.UserHomeDir()